@@ -0,0 +1,135 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BlobStore is a content-addressed store for chunk bytes. SyncTo uses it to find
+// out which chunks a remote is missing and upload only those.
+type BlobStore interface {
+	Has(hash [32]byte) (bool, error)
+	Get(hash [32]byte) ([]byte, error)
+	Put(hash [32]byte, data []byte) error
+}
+
+// MemBlobStore is an in-memory BlobStore, useful for tests and for a local cache in
+// front of a slower remote store.
+type MemBlobStore struct {
+	mu   sync.RWMutex
+	data map[[32]byte][]byte
+}
+
+// NewMemBlobStore creates an empty in-memory BlobStore.
+func NewMemBlobStore() *MemBlobStore {
+	return &MemBlobStore{data: make(map[[32]byte][]byte)}
+}
+
+func (m *MemBlobStore) Has(hash [32]byte) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.data[hash]
+	return ok, nil
+}
+
+func (m *MemBlobStore) Get(hash [32]byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.data[hash]
+	if !ok {
+		return nil, fmt.Errorf("fstree: blob %x not found", hash)
+	}
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+func (m *MemBlobStore) Put(hash [32]byte, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	m.data[hash] = stored
+	return nil
+}
+
+// DiskBlobStore is a BlobStore backed by a directory on disk, laid out with a
+// git-style two-character fanout (e.g. "ab/cd1234...") so a single directory never
+// ends up with millions of entries.
+type DiskBlobStore struct {
+	dir string
+}
+
+// NewDiskBlobStore creates a DiskBlobStore rooted at dir, creating it if necessary.
+func NewDiskBlobStore(dir string) (*DiskBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("fstree: create blob store dir: %w", err)
+	}
+	return &DiskBlobStore{dir: dir}, nil
+}
+
+func (d *DiskBlobStore) path(hash [32]byte) string {
+	hexHash := hex.EncodeToString(hash[:])
+	return filepath.Join(d.dir, hexHash[:2], hexHash[2:])
+}
+
+func (d *DiskBlobStore) Has(hash [32]byte) (bool, error) {
+	_, err := os.Stat(d.path(hash))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *DiskBlobStore) Get(hash [32]byte) ([]byte, error) {
+	data, err := os.ReadFile(d.path(hash))
+	if err != nil {
+		return nil, fmt.Errorf("fstree: read blob %x: %w", hash, err)
+	}
+	return data, nil
+}
+
+func (d *DiskBlobStore) Put(hash [32]byte, data []byte) error {
+	p := d.path(hash)
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("fstree: create blob dir: %w", err)
+	}
+	// Content-addressing means many callers can Put overlapping chunks concurrently, so
+	// the tmp file needs a name unique per call - os.CreateTemp's random suffix gives us
+	// that - rather than the fixed p+".tmp" every concurrent Put for the same hash would
+	// otherwise race on.
+	tmp, err := os.CreateTemp(dir, filepath.Base(p)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("fstree: create temp blob file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("fstree: write blob: %w", writeErr)
+	}
+	if closeErr != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("fstree: write blob: %w", closeErr)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("fstree: finalize blob: %w", err)
+	}
+	if err := os.Rename(tmpPath, p); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("fstree: finalize blob: %w", err)
+	}
+	return nil
+}
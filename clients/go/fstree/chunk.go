@@ -0,0 +1,122 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import "crypto/sha256"
+
+// Content-defined chunking target sizes. A 64KB average gives a reasonable balance
+// between dedup granularity and per-chunk overhead for model artifacts and logs;
+// 16KB/256KB bound the worst case so one pathological run of gear-hash collisions
+// can't produce a chunk list that's all tiny or all huge.
+const (
+	minChunkSize = 16 * 1024
+	avgChunkSize = 64 * 1024
+	maxChunkSize = 256 * 1024
+)
+
+// FastCDC normalized chunking (Xia et al.) uses a stricter mask below the
+// avg/midpoint (fewer boundary hits, so chunks grow past the minimum) and a looser
+// mask above it (more boundary hits, pulling long runs back toward the average).
+// avgChunkSize is 2^16, so the "natural" mask is 16 bits; normalization level 2
+// shifts by 2 bits in either direction.
+const (
+	maskSmall = 1<<18 - 1
+	maskLarge = 1<<14 - 1
+)
+
+// gearTable is a deterministic pseudo-random permutation of byte values used by the
+// rolling gear hash. It must be stable across processes and machines: two captures
+// of the same bytes have to land on the same chunk boundaries, or SyncTo would
+// re-upload everything every time.
+var gearTable [256]uint64
+
+func init() {
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range gearTable {
+		seed = splitmix64(seed)
+		gearTable[i] = seed
+	}
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	z := x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Chunk is one content-defined slice of a file, identified by the hash of its
+// bytes. Offset/Length describe where it sits in the file it was cut from, so
+// SyncTo can re-read exactly those bytes without re-chunking.
+type Chunk struct {
+	Hash   [32]byte
+	Offset int64
+	Length int
+}
+
+// chunkData splits data into content-defined chunks. A nil/empty file yields a
+// single zero-length chunk so every FileEntry has at least one chunk to hash.
+func chunkData(data []byte) []Chunk {
+	if len(data) == 0 {
+		return []Chunk{{Hash: sha256.Sum256(nil), Offset: 0, Length: 0}}
+	}
+
+	var chunks []Chunk
+	var offset int64
+	for len(data) > 0 {
+		n := nextChunkLength(data)
+		piece := data[:n]
+		chunks = append(chunks, Chunk{
+			Hash:   sha256.Sum256(piece),
+			Offset: offset,
+			Length: n,
+		})
+		data = data[n:]
+		offset += int64(n)
+	}
+	return chunks
+}
+
+// nextChunkLength returns the length of the next chunk to cut from the front of
+// data using a gear-hash FastCDC boundary search.
+func nextChunkLength(data []byte) int {
+	n := len(data)
+	if n <= minChunkSize {
+		return n
+	}
+
+	end := n
+	if end > maxChunkSize {
+		end = maxChunkSize
+	}
+
+	var hash uint64
+	for i := minChunkSize; i < end; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if i < avgChunkSize {
+			if hash&maskSmall == 0 {
+				return i + 1
+			}
+		} else if hash&maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return end
+}
+
+// merkleRoot hashes a file's chunk hashes together into a single content identity.
+// A file with one chunk is identified by that chunk's hash directly.
+func merkleRoot(chunks []Chunk) [32]byte {
+	if len(chunks) == 1 {
+		return chunks[0].Hash
+	}
+	h := sha256.New()
+	for _, c := range chunks {
+		h.Write(c.Hash[:])
+	}
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
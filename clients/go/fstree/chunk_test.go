@@ -0,0 +1,91 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+)
+
+func TestChunkDataEmpty(t *testing.T) {
+	chunks := chunkData(nil)
+	if len(chunks) != 1 {
+		t.Fatalf("chunkData(nil) = %d chunks, want 1", len(chunks))
+	}
+	want := sha256.Sum256(nil)
+	if chunks[0].Hash != want || chunks[0].Length != 0 || chunks[0].Offset != 0 {
+		t.Fatalf("chunkData(nil) = %+v, want zero-length chunk with hash of nil", chunks[0])
+	}
+}
+
+func TestChunkDataDeterministic(t *testing.T) {
+	data := randomBytes(5 * avgChunkSize)
+	a := chunkData(data)
+	b := chunkData(data)
+	if len(a) != len(b) {
+		t.Fatalf("chunk count differs across runs: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("chunk %d differs across runs: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestChunkDataReassemblesToOriginal(t *testing.T) {
+	data := randomBytes(3 * avgChunkSize)
+	chunks := chunkData(data)
+
+	var total int64
+	for _, c := range chunks {
+		if c.Offset != total {
+			t.Fatalf("chunk offset %d, want %d", c.Offset, total)
+		}
+		piece := data[c.Offset : c.Offset+int64(c.Length)]
+		if sha256.Sum256(piece) != c.Hash {
+			t.Fatalf("chunk at offset %d has a hash not matching its bytes", c.Offset)
+		}
+		total += int64(c.Length)
+	}
+	if total != int64(len(data)) {
+		t.Fatalf("chunks cover %d bytes, want %d", total, len(data))
+	}
+}
+
+func TestChunkDataBounds(t *testing.T) {
+	data := randomBytes(5 * avgChunkSize)
+	chunks := chunkData(data)
+	for i, c := range chunks {
+		last := i == len(chunks)-1
+		if c.Length < minChunkSize && !last {
+			t.Fatalf("non-final chunk %d is %d bytes, below minChunkSize %d", i, c.Length, minChunkSize)
+		}
+		if c.Length > maxChunkSize {
+			t.Fatalf("chunk %d is %d bytes, above maxChunkSize %d", i, c.Length, maxChunkSize)
+		}
+	}
+}
+
+func TestMerkleRootSingleChunkIsChunkHash(t *testing.T) {
+	chunks := []Chunk{{Hash: sha256.Sum256([]byte("only chunk"))}}
+	if got := merkleRoot(chunks); got != chunks[0].Hash {
+		t.Fatalf("merkleRoot of a single chunk = %x, want the chunk's own hash %x", got, chunks[0].Hash)
+	}
+}
+
+func TestMerkleRootChangesWithChunkOrder(t *testing.T) {
+	c1 := Chunk{Hash: sha256.Sum256([]byte("a"))}
+	c2 := Chunk{Hash: sha256.Sum256([]byte("b"))}
+	if merkleRoot([]Chunk{c1, c2}) == merkleRoot([]Chunk{c2, c1}) {
+		t.Fatal("merkleRoot should be sensitive to chunk order, since chunk order reflects file content order")
+	}
+}
+
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	r := rand.New(rand.NewSource(1))
+	_, _ = r.Read(data)
+	return data
+}
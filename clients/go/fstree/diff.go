@@ -0,0 +1,60 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+// SnapshotDiff is the result of comparing two Snapshots of the same tree taken at
+// different times.
+type SnapshotDiff struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+
+	// NewChunks holds every chunk referenced by an Added or Modified file whose
+	// hash doesn't already appear anywhere in base, keyed by content hash so a
+	// chunk shared by several files in the new snapshot is only listed once.
+	// SyncTo does not consult it: SyncTo asks the BlobStore about every chunk.
+	NewChunks map[[32]byte]Chunk
+}
+
+// Diff compares the receiver against base (the earlier snapshot) and returns what
+// changed, at both file and chunk granularity.
+func (s *Snapshot) Diff(base *Snapshot) (*SnapshotDiff, error) {
+	baseFiles := make(map[string]FileEntry, len(base.Files))
+	baseChunks := make(map[[32]byte]struct{})
+	for _, f := range base.Files {
+		baseFiles[f.Path] = f
+		for _, c := range f.Chunks {
+			baseChunks[c.Hash] = struct{}{}
+		}
+	}
+
+	diff := &SnapshotDiff{NewChunks: make(map[[32]byte]Chunk)}
+
+	seen := make(map[string]struct{}, len(s.Files))
+	for _, f := range s.Files {
+		seen[f.Path] = struct{}{}
+		old, existed := baseFiles[f.Path]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, f.Path)
+		case old.Hash != f.Hash:
+			diff.Modified = append(diff.Modified, f.Path)
+		default:
+			continue // unchanged file, no new chunks to consider
+		}
+		for _, c := range f.Chunks {
+			if _, have := baseChunks[c.Hash]; !have {
+				diff.NewChunks[c.Hash] = c
+			}
+		}
+	}
+
+	for path := range baseFiles {
+		if _, stillPresent := seen[path]; !stillPresent {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+
+	return diff, nil
+}
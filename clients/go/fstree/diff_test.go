@@ -0,0 +1,111 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/strongdm/ai-cxdb/clients/go/fstree"
+)
+
+func TestDiffAddedModifiedRemoved(t *testing.T) {
+	tmpDir := t.TempDir()
+	write(t, tmpDir, "keep.txt", "keep")
+	write(t, tmpDir, "modify.txt", "v1")
+	write(t, tmpDir, "delete.txt", "bye")
+
+	base, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture base: %v", err)
+	}
+
+	write(t, tmpDir, "modify.txt", "v2")
+	write(t, tmpDir, "new.txt", "hello")
+	if err := os.Remove(filepath.Join(tmpDir, "delete.txt")); err != nil {
+		t.Fatalf("remove delete.txt: %v", err)
+	}
+
+	next, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture next: %v", err)
+	}
+
+	diff, err := next.Diff(base)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+
+	assertStringsEqual(t, "Added", diff.Added, []string{"new.txt"})
+	assertStringsEqual(t, "Modified", diff.Modified, []string{"modify.txt"})
+	assertStringsEqual(t, "Removed", diff.Removed, []string{"delete.txt"})
+}
+
+func TestDiffNoChangesYieldsEmptyDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	write(t, tmpDir, "a.txt", "a")
+
+	snap, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	diff, err := snap.Diff(snap)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diff.Added) != 0 || len(diff.Modified) != 0 || len(diff.Removed) != 0 {
+		t.Fatalf("diff against itself should be empty, got %+v", diff)
+	}
+}
+
+func TestDiffNewChunksExcludesChunksAlreadyInBase(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Both files share identical content, so their chunks are identical too.
+	write(t, tmpDir, "a.txt", "shared content")
+	base, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture base: %v", err)
+	}
+
+	write(t, tmpDir, "b.txt", "shared content")
+	next, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture next: %v", err)
+	}
+
+	diff, err := next.Diff(base)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "b.txt" {
+		t.Fatalf("Added = %v, want [b.txt]", diff.Added)
+	}
+	if len(diff.NewChunks) != 0 {
+		t.Fatalf("NewChunks = %v, want none: b.txt's chunk is already present in base via a.txt", diff.NewChunks)
+	}
+}
+
+func write(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func assertStringsEqual(t *testing.T, label string, got, want []string) {
+	t.Helper()
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", label, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", label, got, want)
+		}
+	}
+}
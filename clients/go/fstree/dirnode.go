@@ -0,0 +1,143 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"crypto/sha256"
+	"sort"
+	"strings"
+)
+
+// dirNode is one directory in a WatchingTracker's live in-memory Merkle tree. Unlike
+// Capture's one-shot flat file list, this tree is mutated in place as fsnotify
+// events arrive, with each node caching its own hash so a single file change only
+// re-hashes that file plus its ancestor directories, not the whole tree.
+type dirNode struct {
+	parent  *dirNode
+	files   map[string]FileEntry
+	subdirs map[string]*dirNode
+
+	hash  [32]byte
+	dirty bool
+}
+
+func newDirNode(parent *dirNode) *dirNode {
+	return &dirNode{
+		parent:  parent,
+		files:   make(map[string]FileEntry),
+		subdirs: make(map[string]*dirNode),
+		dirty:   true,
+	}
+}
+
+// markDirty invalidates this node's cached hash and every ancestor's. It stops
+// early once it reaches a node that's already dirty, since that node's ancestors
+// must have been marked on a previous call.
+func (n *dirNode) markDirty() {
+	for cur := n; cur != nil && !cur.dirty; cur = cur.parent {
+		cur.dirty = true
+	}
+}
+
+// hashOf returns the node's content hash, recomputing it if it was marked dirty.
+// Dirty children are recomputed along the way; clean ones contribute their cached
+// hash. Subdirectories with no files anywhere beneath them are left out, since
+// buildHashTree never creates such nodes.
+func (n *dirNode) hashOf() [32]byte {
+	if !n.dirty {
+		return n.hash
+	}
+
+	var hasFiles func(d *dirNode) bool
+	hasFiles = func(d *dirNode) bool {
+		if len(d.files) > 0 {
+			return true
+		}
+		for _, sub := range d.subdirs {
+			if hasFiles(sub) {
+				return true
+			}
+		}
+		return false
+	}
+
+	names := make([]string, 0, len(n.files)+len(n.subdirs))
+	for name := range n.files {
+		names = append(names, "f:"+name)
+	}
+	for name, sub := range n.subdirs {
+		if hasFiles(sub) {
+			names = append(names, "d:"+name)
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, tagged := range names {
+		h.Write([]byte(tagged))
+		kind, name := tagged[:2], tagged[2:]
+		if kind == "f:" {
+			fe := n.files[name]
+			h.Write(fe.Hash[:])
+		} else {
+			childHash := n.subdirs[name].hashOf()
+			h.Write(childHash[:])
+		}
+	}
+	copy(n.hash[:], h.Sum(nil))
+	n.dirty = false
+	return n.hash
+}
+
+// buildHashTree constructs a throwaway dirNode tree over files (all nodes starting
+// dirty, so hashOf recomputes from scratch), keyed by splitting each FileEntry's
+// full Path on "/". This lets rootHash compute the same directory-structured hash
+// dirNode.hashOf produces from a flat, sorted file list - the shape Capture and
+// Tracker snapshots come in - without needing a live WatchingTracker tree, so a
+// Snapshot's RootHash is identical across both code paths for the same tree content.
+func buildHashTree(files []FileEntry) *dirNode {
+	root := newDirNode(nil)
+	for _, f := range files {
+		parts := strings.Split(f.Path, "/")
+		cur := root
+		for _, dir := range parts[:len(parts)-1] {
+			child, ok := cur.subdirs[dir]
+			if !ok {
+				child = newDirNode(cur)
+				cur.subdirs[dir] = child
+			}
+			cur = child
+		}
+		cur.files[parts[len(parts)-1]] = f
+	}
+	return root
+}
+
+// countDirs counts subdirectories beneath n, matching Capture's DirCount semantics
+// of not counting the root itself.
+func (n *dirNode) countDirs() int {
+	count := 0
+	for _, sub := range n.subdirs {
+		count += 1 + sub.countDirs()
+	}
+	return count
+}
+
+// flatten appends every file under n to out, with Path rewritten relative to root.
+func (n *dirNode) flatten(prefix string, out *[]FileEntry) {
+	for name, fe := range n.files {
+		fe.Path = joinRel(prefix, name)
+		*out = append(*out, fe)
+	}
+	for name, sub := range n.subdirs {
+		sub.flatten(joinRel(prefix, name), out)
+	}
+}
+
+func joinRel(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRootHashOrderIndependent guards rootHash's contract that RootHash only depends
+// on tree content, not the order files happen to appear in the input slice.
+func TestRootHashOrderIndependent(t *testing.T) {
+	a := []FileEntry{
+		{Path: "a.txt", Hash: sha256.Sum256([]byte("a"))},
+		{Path: "dir/b.txt", Hash: sha256.Sum256([]byte("b"))},
+	}
+	b := []FileEntry{a[1], a[0]}
+
+	if rootHash(a) != rootHash(b) {
+		t.Fatal("rootHash must not depend on input order")
+	}
+}
+
+// TestRootHashDiffersOnContentChange guards against rootHash collapsing distinct
+// trees to the same hash.
+func TestRootHashDiffersOnContentChange(t *testing.T) {
+	a := []FileEntry{{Path: "a.txt", Hash: sha256.Sum256([]byte("v1"))}}
+	b := []FileEntry{{Path: "a.txt", Hash: sha256.Sum256([]byte("v2"))}}
+
+	if rootHash(a) == rootHash(b) {
+		t.Fatal("rootHash must change when a file's content hash changes")
+	}
+}
+
+// TestWatchingTrackerRootHashMatchesCapture is the regression test for the bug where
+// Capture/Tracker (rootHash, a flat sorted-file hash) and WatchingTracker
+// (dirNode.hashOf, a recursive tree hash) disagreed on RootHash for identical tree
+// content. A caller diffing a Tracker snapshot against a WatchingTracker snapshot of
+// the same unchanged tree must see the same RootHash.
+func TestWatchingTrackerRootHashMatchesCapture(t *testing.T) {
+	tmpDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tmpDir, "README.md"), "# hi")
+	mustMkdirAll(t, filepath.Join(tmpDir, "src"))
+	mustWriteFile(t, filepath.Join(tmpDir, "src", "main.go"), "package main")
+	mustWriteFile(t, filepath.Join(tmpDir, "src", "util.go"), "package main\nfunc f() {}")
+
+	snap, err := Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	wt, err := NewWatchingTracker(tmpDir)
+	if err != nil {
+		t.Fatalf("NewWatchingTracker: %v", err)
+	}
+	defer wt.Close()
+
+	wt.mu.Lock()
+	watchSnap := wt.snapshotLocked()
+	wt.mu.Unlock()
+
+	if watchSnap.RootHash != snap.RootHash {
+		t.Fatalf("RootHash mismatch between Capture (%x) and WatchingTracker (%x) for the same tree",
+			snap.RootHash, watchSnap.RootHash)
+	}
+}
+
+func mustWriteFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func mustMkdirAll(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("mkdir %s: %v", path, err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fstree captures content-addressed snapshots of a directory tree so
+// workspace state (source, model artifacts, logs) can be hashed, diffed, and
+// synced incrementally instead of re-uploaded wholesale on every change.
+package fstree
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileEntry describes one captured file. Hash is the Merkle root over Chunks, so a
+// single-byte change anywhere in a large file only changes the chunks around it
+// (and therefore Hash), not the whole-file identity of every other chunk.
+type FileEntry struct {
+	Path   string
+	Hash   [32]byte
+	Size   int64
+	Mode   os.FileMode
+	Chunks []Chunk
+}
+
+// Stats summarizes a single Capture run.
+type Stats struct {
+	FileCount  int
+	DirCount   int
+	TotalBytes int64
+	Duration   time.Duration
+
+	// OverflowCount counts how many times a WatchingTracker's fsnotify queue
+	// overflowed and had to fall back to a full re-walk. Always 0 for a plain
+	// Capture or Tracker.
+	OverflowCount int
+}
+
+// Snapshot is the content-addressed state of a directory tree at the moment it was
+// captured.
+type Snapshot struct {
+	// Root is the absolute path Capture walked. SyncTo re-reads chunk bytes from
+	// here, so a Snapshot is only useful for syncing as long as Root still exists.
+	Root     string
+	RootHash [32]byte
+	Files    []FileEntry
+	Stats    Stats
+}
+
+type options struct {
+	excludes []string
+	debounce time.Duration
+}
+
+// Option configures Capture and NewTracker.
+type Option func(*options)
+
+// WithExclude skips any path matching one of the given glob patterns. A pattern
+// ending in "/**" excludes an entire directory subtree (e.g. ".git/**"); a pattern
+// with no "/" matches by basename anywhere in the tree (e.g. "*.log").
+func WithExclude(patterns ...string) Option {
+	return func(o *options) {
+		o.excludes = append(o.excludes, patterns...)
+	}
+}
+
+// Capture walks root and builds a content-addressed Snapshot of every regular file
+// under it, skipping anything matched by a WithExclude pattern.
+func Capture(root string, opts ...Option) (*Snapshot, error) {
+	start := time.Now()
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{Root: absRoot}
+
+	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == absRoot {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if matchExcludeDir(o.excludes, rel) {
+				return fs.SkipDir
+			}
+			snap.Stats.DirCount++
+			return nil
+		}
+		if matchExclude(o.excludes, rel) {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		chunks := chunkData(data)
+		entry := FileEntry{
+			Path:   rel,
+			Hash:   merkleRoot(chunks),
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+			Chunks: chunks,
+		}
+		snap.Files = append(snap.Files, entry)
+		snap.Stats.FileCount++
+		snap.Stats.TotalBytes += entry.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snap.Files, func(i, j int) bool { return snap.Files[i].Path < snap.Files[j].Path })
+	snap.RootHash = rootHash(snap.Files)
+	snap.Stats.Duration = time.Since(start)
+
+	return snap, nil
+}
+
+// ListFiles returns every file path in the snapshot, sorted.
+func (s *Snapshot) ListFiles() ([]string, error) {
+	paths := make([]string, len(s.Files))
+	for i, f := range s.Files {
+		paths[i] = f.Path
+	}
+	return paths, nil
+}
+
+// rootHash computes the same directory-structured hash dirNode.hashOf produces, by
+// building a throwaway dirNode tree over the flat, sorted file list (see
+// buildHashTree) and hashing that. This keeps RootHash identical between a
+// Capture/Tracker snapshot and a WatchingTracker snapshot of the same unchanged
+// tree - both ultimately hash an equivalent dirNode tree.
+func rootHash(files []FileEntry) [32]byte {
+	return buildHashTree(files).hashOf()
+}
+
+func matchExclude(patterns []string, relPath string) bool {
+	for _, p := range patterns {
+		if matchOnePattern(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExcludeDir additionally treats a bare directory-prefix pattern (the part of
+// a "dir/**" pattern before the "/**") as excluding the directory itself, so
+// filepath.WalkDir can prune with fs.SkipDir instead of visiting every descendant.
+func matchExcludeDir(patterns []string, relPath string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "/**") && strings.TrimSuffix(p, "/**") == relPath {
+			return true
+		}
+		if matchOnePattern(p, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOnePattern(pattern, relPath string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		prefix := strings.TrimSuffix(pattern, "/**")
+		return relPath == prefix || strings.HasPrefix(relPath, prefix+"/")
+	}
+	if strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, relPath)
+		return ok
+	}
+	ok, _ := filepath.Match(pattern, filepath.Base(relPath))
+	return ok
+}
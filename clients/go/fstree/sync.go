@@ -0,0 +1,48 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SyncTo uploads every chunk in the snapshot that store doesn't already have. It
+// re-reads chunk bytes from Root, so it must be called before the captured tree
+// changes or disappears. Chunks shared across files (or already uploaded by a
+// previous sync) are only read and uploaded once.
+func (s *Snapshot) SyncTo(store BlobStore) error {
+	uploaded := make(map[[32]byte]struct{})
+
+	for _, f := range s.Files {
+		var data []byte
+
+		for _, c := range f.Chunks {
+			if _, done := uploaded[c.Hash]; done {
+				continue
+			}
+			has, err := store.Has(c.Hash)
+			if err != nil {
+				return fmt.Errorf("fstree: check blob %x: %w", c.Hash, err)
+			}
+			if has {
+				uploaded[c.Hash] = struct{}{}
+				continue
+			}
+
+			if data == nil {
+				data, err = os.ReadFile(filepath.Join(s.Root, f.Path))
+				if err != nil {
+					return fmt.Errorf("fstree: read %s: %w", f.Path, err)
+				}
+			}
+			if err := store.Put(c.Hash, data[c.Offset:c.Offset+int64(c.Length)]); err != nil {
+				return fmt.Errorf("fstree: upload blob %x: %w", c.Hash, err)
+			}
+			uploaded[c.Hash] = struct{}{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/strongdm/ai-cxdb/clients/go/fstree"
+)
+
+func TestSyncToUploadsEveryChunk(t *testing.T) {
+	tmpDir := t.TempDir()
+	write(t, tmpDir, "a.txt", "hello from a")
+	write(t, tmpDir, "b.txt", "hello from b")
+
+	snap, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	store := fstree.NewMemBlobStore()
+	if err := snap.SyncTo(store); err != nil {
+		t.Fatalf("SyncTo: %v", err)
+	}
+
+	for _, f := range snap.Files {
+		for _, c := range f.Chunks {
+			got, err := store.Get(c.Hash)
+			if err != nil {
+				t.Fatalf("blob for %s not uploaded: %v", f.Path, err)
+			}
+			data, err := os.ReadFile(filepath.Join(tmpDir, f.Path))
+			if err != nil {
+				t.Fatalf("read %s: %v", f.Path, err)
+			}
+			want := data[c.Offset : c.Offset+int64(c.Length)]
+			if !bytes.Equal(got, want) {
+				t.Fatalf("uploaded blob for %s doesn't match source bytes", f.Path)
+			}
+		}
+	}
+}
+
+func TestSyncToSkipsChunksAlreadyInStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Identical content, so a.txt and b.txt share one chunk.
+	write(t, tmpDir, "a.txt", "duplicate content")
+	write(t, tmpDir, "b.txt", "duplicate content")
+
+	snap, err := fstree.Capture(tmpDir)
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+
+	store := &countingBlobStore{MemBlobStore: fstree.NewMemBlobStore()}
+	if err := snap.SyncTo(store); err != nil {
+		t.Fatalf("SyncTo: %v", err)
+	}
+	if store.puts != 1 {
+		t.Fatalf("Put called %d times, want 1: a.txt and b.txt share one chunk so it should upload once", store.puts)
+	}
+}
+
+// countingBlobStore wraps MemBlobStore to count Put calls, so tests can assert SyncTo
+// doesn't re-upload a chunk it has already seen.
+type countingBlobStore struct {
+	*fstree.MemBlobStore
+	puts int
+}
+
+func (c *countingBlobStore) Put(hash [32]byte, data []byte) error {
+	c.puts++
+	return c.MemBlobStore.Put(hash, data)
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import "sync"
+
+// Tracker remembers the last Snapshot taken of a directory so callers can poll for
+// changes without diffing against a snapshot they have to store themselves.
+type Tracker struct {
+	root string
+	opts []Option
+
+	mu   sync.Mutex
+	last *Snapshot
+}
+
+// NewTracker creates a Tracker over root. opts are applied to every Capture the
+// Tracker performs.
+func NewTracker(root string, opts ...Option) *Tracker {
+	return &Tracker{root: root, opts: opts}
+}
+
+// SnapshotIfChanged captures root and compares it against the last snapshot this
+// Tracker took. If nothing changed (including on repeated no-op calls) it returns a
+// nil snapshot and changed=false so callers can skip re-processing. The very first
+// call always reports changed=true.
+func (t *Tracker) SnapshotIfChanged() (*Snapshot, bool, error) {
+	snap, err := Capture(t.root, t.opts...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last != nil && t.last.RootHash == snap.RootHash {
+		return nil, false, nil
+	}
+	t.last = snap
+	return snap, true, nil
+}
+
+// Last returns the most recent snapshot this Tracker captured, or nil if none has
+// been taken yet.
+func (t *Tracker) Last() *Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
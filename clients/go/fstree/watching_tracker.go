@@ -0,0 +1,491 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package fstree
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultDebounce is the quiet window a WatchingTracker waits after the last
+// filesystem event before emitting a snapshot, so a burst of editor saves (write,
+// chmod, atomic rename-into-place) collapses into one Changes() delivery.
+const defaultDebounce = 250 * time.Millisecond
+
+// WithDebounce overrides NewWatchingTracker's default 250ms quiet window.
+func WithDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.debounce = d
+	}
+}
+
+// WatchingTracker watches a directory tree with fsnotify and maintains its Merkle
+// tree incrementally: each event re-hashes only the file it touched and folds that
+// change up through ancestor directories, instead of re-walking and re-reading
+// every file the way Tracker.SnapshotIfChanged does.
+type WatchingTracker struct {
+	root     string
+	excludes []string
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+
+	mu    sync.Mutex
+	tree  *dirNode
+	stats Stats
+
+	changes   chan *Snapshot
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWatchingTracker captures an initial snapshot of root, then watches it for
+// changes. Call Changes() for a stream of incrementally-updated snapshots, and
+// Close() to stop watching.
+func NewWatchingTracker(root string, opts ...Option) (*WatchingTracker, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	debounce := o.debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, stats, err := buildTree(absRoot, o.excludes)
+	if err != nil {
+		return nil, fmt.Errorf("fstree: initial capture: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("fstree: create watcher: %w", err)
+	}
+	if err := addWatches(watcher, absRoot, o.excludes); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("fstree: add watches: %w", err)
+	}
+
+	t := &WatchingTracker{
+		root:     absRoot,
+		excludes: o.excludes,
+		watcher:  watcher,
+		debounce: debounce,
+		tree:     tree,
+		stats:    stats,
+		changes:  make(chan *Snapshot, 1),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+// Changes streams a new Snapshot each time the watched tree settles after a burst
+// of edits. The channel is closed after Close() drains the watcher's event loop.
+func (t *WatchingTracker) Changes() <-chan *Snapshot {
+	return t.changes
+}
+
+// Close stops the watcher and its event loop.
+func (t *WatchingTracker) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.done)
+		err = t.watcher.Close()
+	})
+	return err
+}
+
+func (t *WatchingTracker) run() {
+	defer close(t.changes)
+
+	var debounceTimer *time.Timer
+	var debounceC <-chan time.Time
+
+	for {
+		select {
+		case <-t.done:
+			return
+
+		case event, ok := <-t.watcher.Events:
+			if !ok {
+				return
+			}
+			t.handleEvent(event)
+			if debounceTimer == nil {
+				debounceTimer = time.NewTimer(t.debounce)
+			} else {
+				if !debounceTimer.Stop() {
+					<-debounceTimer.C
+				}
+				debounceTimer.Reset(t.debounce)
+			}
+			debounceC = debounceTimer.C
+
+		case err, ok := <-t.watcher.Errors:
+			if !ok {
+				return
+			}
+			if isOverflowError(err) {
+				t.mu.Lock()
+				t.stats.OverflowCount++
+				t.mu.Unlock()
+				log.Printf("fstree: watch queue overflowed, falling back to full re-walk: %v", err)
+				t.fullRewalk()
+				continue
+			}
+			log.Printf("fstree: watcher error: %v", err)
+
+		case <-debounceC:
+			debounceC = nil
+			// The fired timer's value is consumed; the next event starts a fresh one.
+			debounceTimer = nil
+			t.emit()
+		}
+	}
+}
+
+func isOverflowError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "overflow")
+}
+
+// handleEvent applies a single fsnotify event to the in-memory tree. Rename is
+// handled as a removal of the old path: on Linux a same-directory rename arrives as
+// a paired IN_MOVED_FROM/IN_MOVED_TO, which fsnotify surfaces as Rename (old name)
+// followed by a separate Create (new name), so the Create branch below picks the
+// file back up under its new path.
+func (t *WatchingTracker) handleEvent(event fsnotify.Event) {
+	rel, err := filepath.Rel(t.root, event.Name)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return
+	}
+	if matchExclude(t.excludes, rel) || matchExcludeDir(t.excludes, rel) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		t.removePathLocked(rel)
+		return
+	}
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return
+	}
+
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		// Most often the file was removed (or renamed away) again before we got to
+		// stat it, e.g. an editor's write-to-temp-then-rename save pattern.
+		t.removePathLocked(rel)
+		return
+	}
+
+	if info.IsDir() {
+		if event.Op&fsnotify.Create == 0 {
+			return
+		}
+		if err := addWatches(t.watcher, t.root, t.excludes); err != nil {
+			log.Printf("fstree: watch new directory %s: %v", rel, err)
+		}
+		t.graftDirLocked(rel, event.Name)
+		return
+	}
+
+	if err := t.insertFileLocked(rel, event.Name); err != nil {
+		log.Printf("fstree: re-hash %s: %v", rel, err)
+	}
+}
+
+func (t *WatchingTracker) insertFileLocked(rel, absPath string) error {
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return err
+	}
+	chunks := chunkData(data)
+	fe := FileEntry{
+		Path:   rel,
+		Hash:   merkleRoot(chunks),
+		Size:   info.Size(),
+		Mode:   info.Mode(),
+		Chunks: chunks,
+	}
+
+	dir, base := splitRel(rel)
+	node := t.nodeForDirLocked(dir)
+	node.files[base] = fe
+	node.markDirty()
+	return nil
+}
+
+func (t *WatchingTracker) removePathLocked(rel string) {
+	dir, base := splitRel(rel)
+	node := t.lookupDirLocked(dir)
+	if node == nil {
+		return
+	}
+	if _, ok := node.files[base]; ok {
+		delete(node.files, base)
+		node.markDirty()
+		return
+	}
+	if _, ok := node.subdirs[base]; ok {
+		delete(node.subdirs, base)
+		node.markDirty()
+	}
+}
+
+// graftDirLocked (re)populates a whole subtree in one pass, for a Create event on a
+// directory that may already contain files - e.g. a directory moved or copied in
+// wholesale rather than built up file by file.
+func (t *WatchingTracker) graftDirLocked(rel, absPath string) {
+	node := t.nodeForDirLocked(rel)
+	node.files = make(map[string]FileEntry)
+	node.subdirs = make(map[string]*dirNode)
+
+	_ = filepath.WalkDir(absPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == absPath {
+			return nil
+		}
+		r, relErr := filepath.Rel(t.root, p)
+		if relErr != nil {
+			return nil
+		}
+		r = filepath.ToSlash(r)
+		if d.IsDir() {
+			if matchExcludeDir(t.excludes, r) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if matchExclude(t.excludes, r) {
+			return nil
+		}
+		if err := t.insertFileLocked(r, p); err != nil {
+			log.Printf("fstree: graft %s: %v", r, err)
+		}
+		return nil
+	})
+	node.markDirty()
+}
+
+func (t *WatchingTracker) nodeForDirLocked(rel string) *dirNode {
+	if rel == "" {
+		return t.tree
+	}
+	cur := t.tree
+	for _, part := range strings.Split(rel, "/") {
+		child, ok := cur.subdirs[part]
+		if !ok {
+			child = newDirNode(cur)
+			cur.subdirs[part] = child
+			cur.markDirty()
+		}
+		cur = child
+	}
+	return cur
+}
+
+func (t *WatchingTracker) lookupDirLocked(rel string) *dirNode {
+	if rel == "" {
+		return t.tree
+	}
+	cur := t.tree
+	for _, part := range strings.Split(rel, "/") {
+		child, ok := cur.subdirs[part]
+		if !ok {
+			return nil
+		}
+		cur = child
+	}
+	return cur
+}
+
+// fullRewalk discards the in-memory tree and rebuilds it from scratch, used when
+// the fsnotify queue overflows and incremental tracking can no longer be trusted.
+func (t *WatchingTracker) fullRewalk() {
+	t.mu.Lock()
+	tree, stats, err := buildTree(t.root, t.excludes)
+	if err != nil {
+		log.Printf("fstree: full re-walk failed: %v", err)
+		t.mu.Unlock()
+		return
+	}
+	stats.OverflowCount = t.stats.OverflowCount
+	t.tree = tree
+	t.stats = stats
+	t.mu.Unlock()
+
+	if err := addWatches(t.watcher, t.root, t.excludes); err != nil {
+		log.Printf("fstree: re-add watches after overflow: %v", err)
+	}
+	t.emit()
+}
+
+func (t *WatchingTracker) emit() {
+	t.mu.Lock()
+	snap := t.snapshotLocked()
+	t.mu.Unlock()
+
+	select {
+	case t.changes <- snap:
+		return
+	default:
+	}
+	// Consumer hasn't drained the last snapshot yet - replace it so a slow reader
+	// still ends up with current state instead of stalling the watch loop.
+	select {
+	case <-t.changes:
+	default:
+	}
+	select {
+	case t.changes <- snap:
+	default:
+	}
+}
+
+func (t *WatchingTracker) snapshotLocked() *Snapshot {
+	var files []FileEntry
+	t.tree.flatten("", &files)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	stats := t.stats
+	stats.FileCount = len(files)
+	stats.DirCount = t.tree.countDirs()
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	stats.TotalBytes = total
+
+	return &Snapshot{
+		Root:     t.root,
+		RootHash: t.tree.hashOf(),
+		Files:    files,
+		Stats:    stats,
+	}
+}
+
+func splitRel(rel string) (dir, base string) {
+	idx := strings.LastIndex(rel, "/")
+	if idx < 0 {
+		return "", rel
+	}
+	return rel[:idx], rel[idx+1:]
+}
+
+// buildTree walks root once, building the same content it would produce via
+// Capture but as a dirNode tree instead of a flat file list, so a WatchingTracker
+// can fold future changes into it incrementally.
+func buildTree(root string, excludes []string) (*dirNode, Stats, error) {
+	tree := newDirNode(nil)
+	var stats Stats
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if matchExcludeDir(excludes, rel) {
+				return fs.SkipDir
+			}
+			stats.DirCount++
+			return nil
+		}
+		if matchExclude(excludes, rel) || !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		chunks := chunkData(data)
+		dir, base := splitRel(rel)
+		node := tree
+		if dir != "" {
+			for _, part := range strings.Split(dir, "/") {
+				child, ok := node.subdirs[part]
+				if !ok {
+					child = newDirNode(node)
+					node.subdirs[part] = child
+				}
+				node = child
+			}
+		}
+		node.files[base] = FileEntry{
+			Path:   rel,
+			Hash:   merkleRoot(chunks),
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+			Chunks: chunks,
+		}
+		stats.FileCount++
+		stats.TotalBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	return tree, stats, nil
+}
+
+// addWatches recursively adds root and every non-excluded subdirectory to watcher.
+// fsnotify's inotify/kqueue backends don't support recursive watches natively, so
+// new directories (including ones created after the tracker starts) must be added
+// individually; this is safe to call repeatedly since fsnotify no-ops on a path
+// that's already watched.
+func addWatches(watcher *fsnotify.Watcher, root string, excludes []string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root {
+			rel, relErr := filepath.Rel(root, path)
+			if relErr == nil && matchExcludeDir(excludes, filepath.ToSlash(rel)) {
+				return fs.SkipDir
+			}
+		}
+		return watcher.Add(path)
+	})
+}
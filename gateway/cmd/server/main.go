@@ -11,6 +11,9 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/strongdm/cxdb/gateway/internal/config"
 	"github.com/strongdm/cxdb/gateway/pkg/auth"
@@ -18,8 +21,8 @@ import (
 )
 
 // Entry point for the cxdb Gateway server.
-// This gateway provides Google OAuth authentication for reads while
-// forwarding writes directly to the cxdb backend.
+// This gateway authenticates reads via one or more registered OIDC-style providers
+// (Google, Keycloak, GitHub) while forwarding writes directly to the cxdb backend.
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level:     slog.LevelInfo,
@@ -48,6 +51,21 @@ func main() {
 	}
 	defer func() { _ = sessionStore.Close() }()
 
+	var sessionEncoder auth.SessionEncoder
+	var cookieStore *auth.CookieStore
+	if cfg.CookieSessionsEnabled {
+		cookieStore, err = auth.NewCookieStore(cfg.CookieName, cfg.CookieDomain, cookieSecure, int(cfg.SessionTTL.Seconds()), cfg.SessionEncryptionKeys...)
+		if err != nil {
+			logger.Error("cookie session store init failed", "err", err)
+			os.Exit(1)
+		}
+		sessionEncoder = cookieStore
+	} else {
+		sessionEncoder = auth.NewServerStore(sessionStore)
+	}
+
+	providers := auth.NewProviderRegistry(sessionStore, sessionEncoder)
+
 	googleAuth := auth.NewGoogleAuth(
 		cfg.PublicBaseURL,
 		cfg.GoogleClientID,
@@ -55,13 +73,58 @@ func main() {
 		cfg.GoogleAllowedDomain,
 		cfg.PublicAllowedHosts,
 		sessionStore,
+		sessionEncoder,
+		cfg.SessionSecret,
 	)
+	providers.Register(googleAuth)
+
+	if cfg.KeycloakEnabled {
+		keycloakAuth, err := auth.NewOIDCProvider(
+			"keycloak",
+			cfg.KeycloakIssuerURL,
+			cfg.KeycloakClientID,
+			cfg.KeycloakClientSecret,
+			cfg.PublicBaseURL,
+			nil,
+			cfg.KeycloakAllowedDomain,
+			cfg.SessionSecret,
+			sessionStore,
+			sessionEncoder,
+		)
+		if err != nil {
+			logger.Error("keycloak provider init failed", "err", err)
+			os.Exit(1)
+		}
+		providers.Register(keycloakAuth)
+	}
+
+	if cfg.GitHubEnabled {
+		providers.Register(auth.NewGitHubProvider(
+			cfg.GitHubClientID,
+			cfg.GitHubClientSecret,
+			cfg.PublicBaseURL,
+			cfg.GitHubAllowedDomain,
+			cfg.SessionSecret,
+			sessionStore,
+			sessionEncoder,
+		))
+	}
+
+	if cookieStore != nil {
+		cookieStore.SetRefreshers(providers.RefresherSet())
+	}
 
 	reverseProxy, err := proxy.NewReverseProxy(cfg.CXDBBackendURL, logger)
 	if err != nil {
 		logger.Error("reverse proxy init failed", "err", err)
 		os.Exit(1)
 	}
+	if cfg.BackendForwardAccessToken {
+		reverseProxy.ForwardAccessToken()
+	}
+	if cfg.BackendMintJWTEnabled {
+		reverseProxy.MintBackendJWT(cfg.SessionSecret)
+	}
 
 	// Extract embedded static assets for the React frontend
 	staticAssets, err := fs.Sub(proxy.EmbeddedStatic, "web")
@@ -70,10 +133,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	changeSource := buildChangeSource(cfg, logger)
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	server, err := proxy.New(cfg, sessionStore, googleAuth, reverseProxy, staticAssets, logger)
+	sessionStore.StartRefresher(ctx, 5*time.Minute, providers.RefresherSet())
+
+	server, err := proxy.New(cfg, sessionStore, providers, reverseProxy, changeSource, staticAssets, logger)
 	if err != nil {
 		logger.Error("server init failed", "err", err)
 		os.Exit(1)
@@ -90,3 +157,42 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// buildChangeSource selects proxy.New's ChangeSource per cfg.ChangeSourceBackend.
+// "poll" (the default) returns nil, letting proxy.New fall back to
+// PollingChangeSource itself; "postgres" opens a dedicated LISTEN/NOTIFY connection so
+// SSEBroker learns about new contexts/turns without polling.
+func buildChangeSource(cfg config.Config, logger *slog.Logger) proxy.ChangeSource {
+	if cfg.ChangeSourceBackend != "postgres" {
+		return nil
+	}
+	listener := pq.NewListener(cfg.ChangeSourcePostgresDSN, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Error("postgres change source listener error", "err", err)
+		}
+	})
+	return proxy.NewPostgresChangeSource(&pqListenerAdapter{listener}, logger)
+}
+
+// pqListenerAdapter adapts *pq.Listener to proxy.PostgresListener, translating
+// *pq.Notification off the Notify field into *proxy.PostgresNotification on a channel -
+// pkg/proxy only depends on its own PostgresListener interface, not lib/pq itself, so
+// picking a postgres driver stays a decision made here at the composition root.
+type pqListenerAdapter struct {
+	*pq.Listener
+}
+
+func (a *pqListenerAdapter) NotificationChannel() <-chan *proxy.PostgresNotification {
+	out := make(chan *proxy.PostgresNotification)
+	go func() {
+		defer close(out)
+		for n := range a.Listener.Notify {
+			if n == nil {
+				out <- nil
+				continue
+			}
+			out <- &proxy.PostgresNotification{Channel: n.Channel, Extra: n.Extra}
+		}
+	}()
+	return out
+}
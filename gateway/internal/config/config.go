@@ -0,0 +1,329 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+// Package config loads the cxdb gateway's runtime configuration from the environment.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the gateway needs at startup. Load populates it from
+// environment variables so the gateway stays a single static binary with no config
+// file to ship alongside it.
+type Config struct {
+	Port           string
+	PublicBaseURL  string
+	CXDBBackendURL string
+	DevMode        bool
+
+	DatabasePath  string
+	CookieName    string
+	CookieDomain  string
+	SessionTTL    time.Duration
+	SessionSecret string
+
+	// CookieSessionsEnabled selects the CookieStore SessionEncoder (session state held
+	// entirely in encrypted browser cookies) instead of the default ServerStore.
+	CookieSessionsEnabled bool
+	// SessionEncryptionKeys is the AES key ring for CookieStore, newest first.
+	SessionEncryptionKeys []string
+
+	GoogleClientID      string
+	GoogleClientSecret  string
+	GoogleAllowedDomain string
+	PublicAllowedHosts  []string
+
+	// KeycloakEnabled (and Okta, Dex, Azure AD, or any other standard OIDC issuer)
+	// mounts a generic OIDCProvider alongside Google, for self-hosted SSO.
+	KeycloakEnabled       bool
+	KeycloakIssuerURL     string
+	KeycloakClientID      string
+	KeycloakClientSecret  string
+	KeycloakAllowedDomain string
+
+	GitHubEnabled       bool
+	GitHubClientID      string
+	GitHubClientSecret  string
+	GitHubAllowedDomain string
+
+	AllowedRendererOrigins []string
+
+	K8sOIDCEnabled           bool
+	K8sOIDCIssuerURL         string
+	K8sOIDCAudience          string
+	K8sOIDCAllowedNamespaces []string
+
+	AWSIAMEnabled      bool
+	AWSIAMAllowedRoles []string
+	AWSIAMTokenTTL     time.Duration
+
+	// TrustedProxies lists CIDRs of reverse proxies/load balancers allowed to set
+	// Forwarded/X-Forwarded-For/X-Real-IP. Requests from any other RemoteAddr have
+	// those headers ignored, so a direct client can't spoof its IP for rate limiting
+	// or audit logs.
+	TrustedProxies []string
+
+	// ProxyProtocolEnabled accepts HAProxy PROXY protocol v1/v2 on ProxyProtocolPort
+	// instead of trusting the TCP source address's headers alone - useful when the
+	// gateway sits behind an L4 load balancer that doesn't rewrite HTTP headers.
+	ProxyProtocolEnabled bool
+	ProxyProtocolPort    string
+
+	// BackendForwardAccessToken forwards the authenticated session's OAuth access
+	// token to the cxdb backend as "Authorization: Bearer <token>", letting it call
+	// back out to the IdP itself if it needs more than the identity headers carry.
+	BackendForwardAccessToken bool
+
+	// BackendMintJWTEnabled mints a short-lived HS256 JWT (sub, email, groups,
+	// aud=cxdb-backend, exp) signed with SessionSecret and forwards it as
+	// "Authorization: Bearer <token>" instead, for backends that expect one token
+	// format regardless of which provider authenticated the caller. When
+	// BackendForwardAccessToken is also set, the real access token wins and a JWT is
+	// only minted for sessions that have no access token to forward.
+	BackendMintJWTEnabled bool
+
+	// AuditEnabled turns on auditing of request bodies and response statuses for
+	// non-GET /v1/* writes.
+	// This is separate from the gateway's own access log (which never captures
+	// bodies) and exists to satisfy compliance requirements for a gateway fronting a
+	// database.
+	AuditEnabled bool
+	// AuditSinks selects which AuditSink implementations receive every audit entry:
+	// any of "stdout", "file", "webhook".
+	AuditSinks []string
+	// AuditMaxBodyBytes caps how much of a request body is captured per entry;
+	// anything beyond this is truncated in the audit record only (the backend still
+	// receives the request in full). <= 0 uses AuditLogger's built-in default.
+	AuditMaxBodyBytes int
+	// AuditRedactPaths is a list of dotted JSON field paths (e.g. "user.password")
+	// whose values are replaced with "[REDACTED]" in a captured request body.
+	AuditRedactPaths []string
+
+	AuditLogFilePath     string
+	AuditLogFileMaxBytes int64
+
+	AuditWebhookURL           string
+	AuditWebhookBatchSize     int
+	AuditWebhookFlushInterval time.Duration
+	AuditWebhookMaxRetries    int
+
+	// ChangeSourceBackend selects how SSEBroker learns about new contexts/turns: "poll"
+	// (default, the original /v1/contexts poll loop) or "postgres" (LISTEN/NOTIFY via
+	// ChangeSourcePostgresDSN), which removes both the poll interval's latency and its
+	// O(N·clients) backend load.
+	ChangeSourceBackend string
+	// ChangeSourcePostgresDSN is the Postgres connection string LISTEN/NOTIFY
+	// subscribes on. Required when ChangeSourceBackend is "postgres".
+	ChangeSourcePostgresDSN string
+}
+
+// Load reads Config from the environment, applying sane defaults for local dev.
+func Load() (Config, error) {
+	cfg := Config{
+		Port:                os.Getenv("PORT"),
+		PublicBaseURL:       os.Getenv("PUBLIC_BASE_URL"),
+		CXDBBackendURL:      os.Getenv("CXDB_BACKEND_URL"),
+		DevMode:             envBool("DEV_MODE", false),
+		DatabasePath:        envOr("SESSION_DB_PATH", "./gateway-sessions.db"),
+		CookieName:          envOr("COOKIE_NAME", "cxdb_session"),
+		CookieDomain:        os.Getenv("COOKIE_DOMAIN"),
+		SessionSecret:       os.Getenv("SESSION_SECRET"),
+		GoogleClientID:      os.Getenv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:  os.Getenv("GOOGLE_CLIENT_SECRET"),
+		GoogleAllowedDomain: os.Getenv("GOOGLE_ALLOWED_DOMAIN"),
+		PublicAllowedHosts:  envList("PUBLIC_ALLOWED_HOSTS"),
+
+		KeycloakEnabled:       envBool("KEYCLOAK_ENABLED", false),
+		KeycloakIssuerURL:     os.Getenv("KEYCLOAK_ISSUER_URL"),
+		KeycloakClientID:      os.Getenv("KEYCLOAK_CLIENT_ID"),
+		KeycloakClientSecret:  os.Getenv("KEYCLOAK_CLIENT_SECRET"),
+		KeycloakAllowedDomain: os.Getenv("KEYCLOAK_ALLOWED_DOMAIN"),
+
+		GitHubEnabled:       envBool("GITHUB_ENABLED", false),
+		GitHubClientID:      os.Getenv("GITHUB_CLIENT_ID"),
+		GitHubClientSecret:  os.Getenv("GITHUB_CLIENT_SECRET"),
+		GitHubAllowedDomain: os.Getenv("GITHUB_ALLOWED_DOMAIN"),
+
+		CookieSessionsEnabled: envBool("COOKIE_SESSIONS_ENABLED", false),
+		SessionEncryptionKeys: envList("SESSION_ENCRYPTION_KEYS"),
+
+		AllowedRendererOrigins: envList("ALLOWED_RENDERER_ORIGINS"),
+
+		K8sOIDCEnabled:           envBool("K8S_OIDC_ENABLED", false),
+		K8sOIDCIssuerURL:         os.Getenv("K8S_OIDC_ISSUER_URL"),
+		K8sOIDCAudience:          os.Getenv("K8S_OIDC_AUDIENCE"),
+		K8sOIDCAllowedNamespaces: envList("K8S_OIDC_ALLOWED_NAMESPACES"),
+
+		AWSIAMEnabled:      envBool("AWS_IAM_ENABLED", false),
+		AWSIAMAllowedRoles: envList("AWS_IAM_ALLOWED_ROLES"),
+
+		TrustedProxies: envList("TRUSTED_PROXIES"),
+
+		ProxyProtocolEnabled: envBool("PROXY_PROTOCOL_ENABLED", false),
+		ProxyProtocolPort:    os.Getenv("PROXY_PROTOCOL_PORT"),
+
+		BackendForwardAccessToken: envBool("BACKEND_FORWARD_ACCESS_TOKEN", false),
+		BackendMintJWTEnabled:     envBool("BACKEND_MINT_JWT_ENABLED", false),
+
+		AuditEnabled:      envBool("AUDIT_ENABLED", false),
+		AuditSinks:        envList("AUDIT_SINKS"),
+		AuditMaxBodyBytes: envInt("AUDIT_MAX_BODY_BYTES", 0),
+		AuditRedactPaths:  envList("AUDIT_REDACT_PATHS"),
+
+		AuditLogFilePath:     envOr("AUDIT_LOG_FILE_PATH", "./gateway-audit.log"),
+		AuditLogFileMaxBytes: envInt64("AUDIT_LOG_FILE_MAX_BYTES", 100*1024*1024),
+
+		AuditWebhookURL:        os.Getenv("AUDIT_WEBHOOK_URL"),
+		AuditWebhookBatchSize:  envInt("AUDIT_WEBHOOK_BATCH_SIZE", 50),
+		AuditWebhookMaxRetries: envInt("AUDIT_WEBHOOK_MAX_RETRIES", 3),
+
+		ChangeSourceBackend:     envOr("CHANGE_SOURCE_BACKEND", "poll"),
+		ChangeSourcePostgresDSN: os.Getenv("CHANGE_SOURCE_POSTGRES_DSN"),
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.PublicBaseURL == "" {
+		return cfg, fmt.Errorf("PUBLIC_BASE_URL is required")
+	}
+	if cfg.CXDBBackendURL == "" {
+		return cfg, fmt.Errorf("CXDB_BACKEND_URL is required")
+	}
+	if cfg.SessionSecret == "" {
+		return cfg, fmt.Errorf("SESSION_SECRET is required")
+	}
+	if cfg.CookieSessionsEnabled && len(cfg.SessionEncryptionKeys) == 0 {
+		cfg.SessionEncryptionKeys = []string{cfg.SessionSecret}
+	}
+
+	if cfg.KeycloakEnabled && (cfg.KeycloakIssuerURL == "" || cfg.KeycloakClientID == "" || cfg.KeycloakClientSecret == "") {
+		return cfg, fmt.Errorf("KEYCLOAK_ISSUER_URL, KEYCLOAK_CLIENT_ID, and KEYCLOAK_CLIENT_SECRET are required when KEYCLOAK_ENABLED=true")
+	}
+	if cfg.GitHubEnabled && (cfg.GitHubClientID == "" || cfg.GitHubClientSecret == "") {
+		return cfg, fmt.Errorf("GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET are required when GITHUB_ENABLED=true")
+	}
+	if cfg.ProxyProtocolEnabled && cfg.ProxyProtocolPort == "" {
+		return cfg, fmt.Errorf("PROXY_PROTOCOL_PORT is required when PROXY_PROTOCOL_ENABLED=true")
+	}
+	for _, sink := range cfg.AuditSinks {
+		if sink != "stdout" && sink != "file" && sink != "webhook" {
+			return cfg, fmt.Errorf("AUDIT_SINKS: unknown sink %q (want stdout, file, or webhook)", sink)
+		}
+	}
+	if cfg.AuditEnabled && len(cfg.AuditSinks) == 0 {
+		return cfg, fmt.Errorf("AUDIT_SINKS is required when AUDIT_ENABLED=true")
+	}
+	if containsString(cfg.AuditSinks, "webhook") && cfg.AuditWebhookURL == "" {
+		return cfg, fmt.Errorf("AUDIT_WEBHOOK_URL is required when AUDIT_SINKS includes \"webhook\"")
+	}
+	if cfg.ChangeSourceBackend != "poll" && cfg.ChangeSourceBackend != "postgres" {
+		return cfg, fmt.Errorf("CHANGE_SOURCE_BACKEND: unknown backend %q (want poll or postgres)", cfg.ChangeSourceBackend)
+	}
+	if cfg.ChangeSourceBackend == "postgres" && cfg.ChangeSourcePostgresDSN == "" {
+		return cfg, fmt.Errorf("CHANGE_SOURCE_POSTGRES_DSN is required when CHANGE_SOURCE_BACKEND=postgres")
+	}
+
+	sessionTTL, err := envDuration("SESSION_TTL", 12*time.Hour)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.SessionTTL = sessionTTL
+
+	auditWebhookFlushInterval, err := envDuration("AUDIT_WEBHOOK_FLUSH_INTERVAL", 5*time.Second)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AuditWebhookFlushInterval = auditWebhookFlushInterval
+
+	awsTokenTTL, err := envDuration("AWS_IAM_TOKEN_TTL", time.Hour)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AWSIAMTokenTTL = awsTokenTTL
+
+	return cfg, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func envList(key string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func envInt64(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func containsString(list []string, want string) bool {
+	for _, v := range list {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
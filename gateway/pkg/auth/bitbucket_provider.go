@@ -0,0 +1,243 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BitbucketProvider implements Provider for bitbucket.org OAuth consumers. Like
+// GitHub, Bitbucket predates OIDC: no discovery document or id_token, so identity
+// comes from the REST /2.0/user and /2.0/user/emails endpoints. Unlike GitHub,
+// Bitbucket does issue refresh tokens.
+type BitbucketProvider struct {
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	allowedDomain string
+	sessionSecret string
+	sessions      *SessionStore
+	encoder       SessionEncoder
+}
+
+// NewBitbucketProvider builds a Bitbucket OAuth consumer provider mounted at
+// /auth/bitbucket/*.
+func NewBitbucketProvider(clientID, clientSecret, publicBaseURL, allowedDomain, sessionSecret string, sessions *SessionStore, encoder SessionEncoder) *BitbucketProvider {
+	return &BitbucketProvider{
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		redirectURL:   strings.TrimSuffix(publicBaseURL, "/") + "/auth/bitbucket/callback",
+		allowedDomain: strings.ToLower(strings.TrimSpace(allowedDomain)),
+		sessionSecret: sessionSecret,
+		sessions:      sessions,
+		encoder:       encoder,
+	}
+}
+
+func (p *BitbucketProvider) ID() string { return "bitbucket" }
+
+// FormActionHosts returns the host Bitbucket's OAuth authorize screen redirects through.
+func (p *BitbucketProvider) FormActionHosts() []string {
+	return []string{"https://bitbucket.org"}
+}
+
+func (p *BitbucketProvider) Login(w http.ResponseWriter, r *http.Request) {
+	st, err := newAuthState(false, false)
+	if err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	if err := setStateCookie(w, p.sessions, p.sessionSecret, st, 10*time.Minute); err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	q := url.Values{
+		"client_id":     {p.clientID},
+		"redirect_uri":  {p.redirectURL},
+		"response_type": {"code"},
+		"state":         {st.State},
+	}
+	http.Redirect(w, r, "https://bitbucket.org/site/oauth2/authorize?"+q.Encode(), http.StatusFound)
+}
+
+type bitbucketTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Error        string `json:"error"`
+}
+
+type bitbucketUser struct {
+	DisplayName string `json:"display_name"`
+	Username    string `json:"username"`
+	Links       struct {
+		Avatar struct {
+			Href string `json:"href"`
+		} `json:"avatar"`
+	} `json:"links"`
+}
+
+type bitbucketEmail struct {
+	Values []struct {
+		Email     string `json:"email"`
+		IsPrimary bool   `json:"is_primary"`
+		Confirmed bool   `json:"is_confirmed"`
+	} `json:"values"`
+}
+
+func (p *BitbucketProvider) Callback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Redirect(w, r, "/login?error=access_denied", http.StatusFound)
+		return
+	}
+	if _, ok := readStateCookie(r, p.sessionSecret, state); !ok {
+		http.Redirect(w, r, "/login?error=state", http.StatusFound)
+		return
+	}
+	clearStateCookie(w, p.sessions)
+
+	tok, err := p.exchangeCode(ctx, code)
+	if err != nil {
+		if p.sessions.Debug() {
+			log.Printf("[bitbucket] exchange error: %v", err)
+		}
+		http.Redirect(w, r, "/login?error=exchange", http.StatusFound)
+		return
+	}
+
+	user, email, err := p.fetchUser(ctx, tok.AccessToken)
+	if err != nil {
+		http.Redirect(w, r, "/login?error=profile", http.StatusFound)
+		return
+	}
+	if p.allowedDomain != "" && !strings.HasSuffix(email, "@"+p.allowedDomain) {
+		http.Redirect(w, r, "/login?error=unauthorized", http.StatusFound)
+		return
+	}
+
+	name := user.DisplayName
+	if name == "" {
+		name = user.Username
+	}
+	now := time.Now()
+	sess := &Session{
+		Email:     strings.ToLower(email),
+		Name:      name,
+		Picture:   user.Links.Avatar.Href,
+		Provider:  p.ID(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.sessions.ttl),
+	}
+	sess.accessToken = tok.AccessToken
+	sess.refreshToken = tok.RefreshToken
+	sess.accessTokenExpiresAt = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	sess.refresher = p.Refresh
+
+	if err := p.encoder.Issue(ctx, w, sess); err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (p *BitbucketProvider) exchangeCode(ctx context.Context, code string) (*bitbucketTokenResponse, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.redirectURL},
+	}
+	return p.postToken(ctx, form)
+}
+
+func (p *BitbucketProvider) postToken(ctx context.Context, form url.Values) (*bitbucketTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://bitbucket.org/site/oauth2/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var tok bitbucketTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("bitbucket oauth error: %s", tok.Error)
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("no access_token in bitbucket response")
+	}
+	return &tok, nil
+}
+
+func (p *BitbucketProvider) fetchUser(ctx context.Context, accessToken string) (bitbucketUser, string, error) {
+	var user bitbucketUser
+	if err := p.getJSON(ctx, "https://api.bitbucket.org/2.0/user", accessToken, &user); err != nil {
+		return bitbucketUser{}, "", err
+	}
+	var emails bitbucketEmail
+	if err := p.getJSON(ctx, "https://api.bitbucket.org/2.0/user/emails", accessToken, &emails); err != nil {
+		return bitbucketUser{}, "", err
+	}
+	for _, e := range emails.Values {
+		if e.IsPrimary && e.Confirmed {
+			return user, e.Email, nil
+		}
+	}
+	return bitbucketUser{}, "", errors.New("no confirmed primary email on bitbucket account")
+}
+
+func (p *BitbucketProvider) getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bitbucket api request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bitbucket api %s returned %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Refresh exchanges refreshToken for a fresh access token.
+func (p *BitbucketProvider) Refresh(ctx context.Context, refreshToken string) (string, time.Time, string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+	tok, err := p.postToken(ctx, form)
+	if err != nil {
+		return "", time.Time{}, "", fmt.Errorf("refresh bitbucket token: %w", err)
+	}
+	newRefresh := tok.RefreshToken
+	if newRefresh == refreshToken {
+		newRefresh = ""
+	}
+	return tok.AccessToken, time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second), newRefresh, nil
+}
+
+// Verify is unsupported: Bitbucket issues opaque access tokens, not ID tokens.
+func (p *BitbucketProvider) Verify(ctx context.Context, idToken, nonce string) (*Session, error) {
+	return nil, errors.New("bitbucket provider does not issue id_tokens")
+}
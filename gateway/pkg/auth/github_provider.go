@@ -0,0 +1,229 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHubProvider implements Provider for github.com OAuth apps. GitHub predates OIDC:
+// there's no id_token, discovery document, or refresh token for classic OAuth apps, so
+// identity comes from the REST /user and /user/emails endpoints instead.
+type GitHubProvider struct {
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	allowedDomain string
+	sessionSecret string
+	sessions      *SessionStore
+	encoder       SessionEncoder
+}
+
+// NewGitHubProvider builds a GitHub OAuth app provider mounted at /auth/github/*.
+func NewGitHubProvider(clientID, clientSecret, publicBaseURL, allowedDomain, sessionSecret string, sessions *SessionStore, encoder SessionEncoder) *GitHubProvider {
+	return &GitHubProvider{
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		redirectURL:   strings.TrimSuffix(publicBaseURL, "/") + "/auth/github/callback",
+		allowedDomain: strings.ToLower(strings.TrimSpace(allowedDomain)),
+		sessionSecret: sessionSecret,
+		sessions:      sessions,
+		encoder:       encoder,
+	}
+}
+
+func (p *GitHubProvider) ID() string { return "github" }
+
+// FormActionHosts returns the host GitHub's OAuth authorize screen redirects through.
+func (p *GitHubProvider) FormActionHosts() []string {
+	return []string{"https://github.com"}
+}
+
+// Login redirects to GitHub's consent screen. GitHub doesn't support PKCE for classic
+// OAuth apps, so only the CSRF state (no code_verifier) is bound into the cookie.
+func (p *GitHubProvider) Login(w http.ResponseWriter, r *http.Request) {
+	st, err := newAuthState(false, false)
+	if err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	if err := setStateCookie(w, p.sessions, p.sessionSecret, st, 10*time.Minute); err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	q := url.Values{
+		"client_id":    {p.clientID},
+		"redirect_uri": {p.redirectURL},
+		"scope":        {"read:user user:email"},
+		"state":        {st.State},
+	}
+	http.Redirect(w, r, "https://github.com/login/oauth/authorize?"+q.Encode(), http.StatusFound)
+}
+
+type githubTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+}
+
+type githubUser struct {
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	Email     string `json:"email"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// Callback exchanges the code for an access token and loads the user's profile and
+// primary verified email.
+func (p *GitHubProvider) Callback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Redirect(w, r, "/login?error=access_denied", http.StatusFound)
+		return
+	}
+	if _, ok := readStateCookie(r, p.sessionSecret, state); !ok {
+		http.Redirect(w, r, "/login?error=state", http.StatusFound)
+		return
+	}
+	clearStateCookie(w, p.sessions)
+
+	accessToken, err := p.exchangeCode(ctx, code)
+	if err != nil {
+		if p.sessions.Debug() {
+			log.Printf("[github] exchange error: %v", err)
+		}
+		http.Redirect(w, r, "/login?error=exchange", http.StatusFound)
+		return
+	}
+
+	user, email, err := p.fetchUser(ctx, accessToken)
+	if err != nil {
+		http.Redirect(w, r, "/login?error=profile", http.StatusFound)
+		return
+	}
+	if p.allowedDomain != "" && !strings.HasSuffix(email, "@"+p.allowedDomain) {
+		http.Redirect(w, r, "/login?error=unauthorized", http.StatusFound)
+		return
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+	now := time.Now()
+	sess := &Session{
+		Email:     strings.ToLower(email),
+		Name:      name,
+		Picture:   user.AvatarURL,
+		Provider:  p.ID(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.sessions.ttl),
+	}
+	sess.accessToken = accessToken
+	// GitHub classic OAuth app tokens don't expire and have no refresh token.
+	sess.accessTokenExpiresAt = now.Add(100 * 365 * 24 * time.Hour)
+
+	if err := p.encoder.Issue(ctx, w, sess); err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (p *GitHubProvider) exchangeCode(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://github.com/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exchange request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var tok githubTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if tok.Error != "" {
+		return "", fmt.Errorf("github oauth error: %s", tok.Error)
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("no access_token in github response")
+	}
+	return tok.AccessToken, nil
+}
+
+func (p *GitHubProvider) fetchUser(ctx context.Context, accessToken string) (githubUser, string, error) {
+	var user githubUser
+	if err := p.getJSON(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return githubUser{}, "", err
+	}
+	if user.Email != "" {
+		return user, user.Email, nil
+	}
+
+	var emails []githubEmail
+	if err := p.getJSON(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return githubUser{}, "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return user, e.Email, nil
+		}
+	}
+	return githubUser{}, "", errors.New("no verified primary email on github account")
+}
+
+func (p *GitHubProvider) getJSON(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("github api request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("github api %s returned %d: %s", endpoint, resp.StatusCode, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Refresh is unsupported: classic GitHub OAuth app tokens don't expire or rotate.
+func (p *GitHubProvider) Refresh(ctx context.Context, refreshToken string) (string, time.Time, string, error) {
+	return "", time.Time{}, "", errors.New("github provider does not support token refresh")
+}
+
+// Verify is unsupported: GitHub issues opaque access tokens, not ID tokens.
+func (p *GitHubProvider) Verify(ctx context.Context, idToken, nonce string) (*Session, error) {
+	return nil, errors.New("github provider does not issue id_tokens")
+}
@@ -28,10 +28,19 @@ type GoogleAuth struct {
 	allowedDomain string
 	allowedHosts  map[string]bool
 	sessions      *SessionStore
+	encoder       SessionEncoder
+	sessionSecret string
 	publicURL     string
 }
 
-func NewGoogleAuth(publicBaseURL string, clientID, clientSecret string, allowedDomain string, allowedHosts []string, sessions *SessionStore) *GoogleAuth {
+// NewGoogleAuth constructs the Google OAuth2 handlers. encoder selects how sessions are
+// carried back to the browser: pass NewServerStore(sessions) for the original opaque
+// session-ID cookie, or a *CookieStore to keep session state (including the refresh
+// token) entirely client-side. sessionSecret signs the PKCE/state cookie shared with
+// every other Provider. sessions is still required for the debug/cookie-domain helpers
+// GoogleAuth uses internally and for the session TTL; the logout handler only uses
+// encoder.
+func NewGoogleAuth(publicBaseURL string, clientID, clientSecret string, allowedDomain string, allowedHosts []string, sessions *SessionStore, encoder SessionEncoder, sessionSecret string) *GoogleAuth {
 	stateAge := 10 * time.Minute
 	hostMap := make(map[string]bool, len(allowedHosts))
 	for _, h := range allowedHosts {
@@ -55,29 +64,79 @@ func NewGoogleAuth(publicBaseURL string, clientID, clientSecret string, allowedD
 		allowedDomain: strings.ToLower(strings.TrimSpace(allowedDomain)),
 		allowedHosts:  hostMap,
 		sessions:      sessions,
+		encoder:       encoder,
+		sessionSecret: sessionSecret,
 		publicURL:     publicBaseURL,
 	}
 }
 
+// ID implements Provider.
+func (g *GoogleAuth) ID() string { return "google" }
+
+// FormActionHosts returns the hosts Google's consent screen and account chooser
+// redirect through.
+func (g *GoogleAuth) FormActionHosts() []string {
+	return []string{"https://accounts.google.com", "https://*.google.com"}
+}
+
+// Login implements Provider by delegating to LoginHandler.
+func (g *GoogleAuth) Login(w http.ResponseWriter, r *http.Request) { g.LoginHandler(w, r) }
+
+// Callback implements Provider by delegating to CallbackHandler.
+func (g *GoogleAuth) Callback(w http.ResponseWriter, r *http.Request) { g.CallbackHandler(w, r) }
+
+// Refresh implements Provider.
+func (g *GoogleAuth) Refresh(ctx context.Context, refreshToken string) (string, time.Time, string, error) {
+	return g.refresher(ctx, refreshToken)
+}
+
+// Verify implements Provider. GoogleAuth authenticates via the userinfo endpoint
+// rather than a signed id_token, so there is nothing to verify out of band.
+func (g *GoogleAuth) Verify(ctx context.Context, idToken, nonce string) (*Session, error) {
+	return nil, errors.New("google provider authenticates via userinfo, not id_token")
+}
+
+// RefreshFunc exposes the Google token refresher so callers can register it with
+// SessionStore.StartRefresher under the "google" provider id.
+func (g *GoogleAuth) RefreshFunc() RefreshFunc {
+	return g.refresher
+}
+
+// refresher exchanges a Google refresh token for a fresh access token. It is attached
+// to every Session this provider mints so Session.AccessToken(ctx) and the background
+// refresh sweep can renew it transparently.
+func (g *GoogleAuth) refresher(ctx context.Context, refreshToken string) (string, time.Time, string, error) {
+	ts := g.cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken})
+	tok, err := ts.Token()
+	if err != nil {
+		return "", time.Time{}, "", fmt.Errorf("refresh google token: %w", err)
+	}
+	newRefresh := tok.RefreshToken
+	if newRefresh == refreshToken {
+		newRefresh = ""
+	}
+	return tok.AccessToken, tok.Expiry, newRefresh, nil
+}
+
 // LoginHandler redirects users to Google's consent screen.
 func (g *GoogleAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
-	state, err := randomState()
+	st, err := newAuthState(true, false)
 	if err != nil {
 		http.Error(w, "unable to create state", http.StatusInternalServerError)
 		return
 	}
 	g.setPostAuthRedirectCookie(w, r)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    state,
-		Domain:   g.sessions.Domain(),
-		Path:     "/",
-		MaxAge:   int(g.stateMaxAge.Seconds()),
-		HttpOnly: true,
-		Secure:   g.sessions.Secure(),
-		SameSite: http.SameSiteLaxMode,
-	})
-	authURL := g.cfg.AuthCodeURL(state, oauth2.AccessTypeOnline)
+	if err := setStateCookie(w, g.sessions, g.sessionSecret, st, g.stateMaxAge); err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	// AccessTypeOffline + prompt=consent ensures Google issues a refresh token even for
+	// returning users who already granted consent once. S256ChallengeOption adds PKCE.
+	authURL := g.cfg.AuthCodeURL(st.State,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("prompt", "consent"),
+		oauth2.S256ChallengeOption(st.CodeVerifier),
+	)
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
@@ -92,12 +151,13 @@ func (g *GoogleAuth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !g.validState(r, state) {
+	st, ok := readStateCookie(r, g.sessionSecret, state)
+	if !ok {
 		http.Redirect(w, r, "/login?error=state", http.StatusFound)
 		return
 	}
 
-	token, err := g.cfg.Exchange(ctx, code)
+	token, err := g.cfg.Exchange(ctx, code, oauth2.VerifierOption(st.CodeVerifier))
 	if err != nil {
 		if g.sessions.Debug() {
 			log.Printf("[auth] exchange error: %v", err)
@@ -130,16 +190,28 @@ func (g *GoogleAuth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		name = email
 	}
 
-	sessionID, err := g.sessions.Create(ctx, email, name, user.Picture)
-	if err != nil {
+	now := time.Now()
+	sess := &Session{
+		Email:     email,
+		Name:      name,
+		Picture:   user.Picture,
+		Provider:  "google",
+		CreatedAt: now,
+		ExpiresAt: now.Add(g.sessions.ttl),
+	}
+	sess.refresher = g.refresher
+	sess.accessToken = token.AccessToken
+	sess.refreshToken = token.RefreshToken
+	sess.accessTokenExpiresAt = token.Expiry
+
+	if err := g.encoder.Issue(ctx, w, sess); err != nil {
 		if g.sessions.Debug() {
 			log.Printf("[auth] create session error: %v", err)
 		}
 		http.Error(w, "unable to create session", http.StatusInternalServerError)
 		return
 	}
-	g.sessions.SetCookie(w, sessionID)
-	g.clearStateCookie(w)
+	clearStateCookie(w, g.sessions)
 	if dest := g.postAuthRedirect(w, r); dest != "" {
 		http.Redirect(w, r, dest, http.StatusFound)
 		return
@@ -150,10 +222,7 @@ func (g *GoogleAuth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 // LogoutHandler clears the session and redirects to login.
 func (g *GoogleAuth) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	if sess, _ := g.sessions.SessionFromRequest(ctx, r); sess != nil {
-		_ = g.sessions.Delete(ctx, sess.ID)
-	}
-	g.sessions.ClearCookie(w)
+	_ = g.encoder.Clear(ctx, w, r)
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
@@ -183,17 +252,6 @@ func (g *GoogleAuth) fetchUser(ctx context.Context, token *oauth2.Token) (google
 	return u, nil
 }
 
-func (g *GoogleAuth) validState(r *http.Request, state string) bool {
-	if state == "" {
-		return false
-	}
-	c, err := r.Cookie("oauth_state")
-	if err != nil {
-		return false
-	}
-	return subtleEqual(state, c.Value)
-}
-
 func randomState() (string, error) {
 	var b [16]byte
 	if _, err := rand.Read(b[:]); err != nil {
@@ -202,19 +260,6 @@ func randomState() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b[:]), nil
 }
 
-func (g *GoogleAuth) clearStateCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "oauth_state",
-		Value:    "",
-		Domain:   g.sessions.Domain(),
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   g.sessions.Secure(),
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   -1,
-	})
-}
-
 func (g *GoogleAuth) setPostAuthRedirectCookie(w http.ResponseWriter, r *http.Request) {
 	host := canonicalHost(r)
 	if host == "" {
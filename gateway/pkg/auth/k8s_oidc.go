@@ -114,6 +114,7 @@ func (v *K8sOIDCVerifier) Verify(tokenString string) (*Session, error) {
 		ID:        fmt.Sprintf("k8s:%s:%s", namespace, saName),
 		Email:     fmt.Sprintf("%s/%s@k8s.local", namespace, saName),
 		Name:      fmt.Sprintf("ServiceAccount: %s/%s", namespace, saName),
+		Groups:    []string{fmt.Sprintf("system:serviceaccounts:%s", namespace)},
 		CreatedAt: token.IssuedAt(),
 		ExpiresAt: token.Expiration(),
 	}, nil
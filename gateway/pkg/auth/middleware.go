@@ -0,0 +1,105 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// BearerTokenVerifier validates a raw Authorization: Bearer token and returns the
+// Session it describes. K8sOIDCVerifier and AWSTokenExchanger both implement this so
+// RequireAuthForReadsWithOptions can treat service-to-service auth the same way
+// regardless of which token format issued it.
+type BearerTokenVerifier interface {
+	Verify(tokenString string) (*Session, error)
+}
+
+// AuthMiddlewareOptions configures RequireAuthForReadsWithOptions.
+type AuthMiddlewareOptions struct {
+	// Encoder reads back the browser session cookie set by a Provider login, however
+	// that session is actually stored (ServerStore's SQLite row or CookieStore's sealed
+	// payload) - it must be the same SessionEncoder the providers were constructed with.
+	Encoder SessionEncoder
+	// DevBypass skips authentication entirely (local dev only).
+	DevBypass bool
+	// TokenVerifiers are tried, in order, against an Authorization: Bearer header
+	// before falling back to the session cookie.
+	TokenVerifiers []BearerTokenVerifier
+}
+
+var middlewareDebug = strings.Contains(os.Getenv("DEBUG"), "auth") || strings.Contains(os.Getenv("DEBUG"), "all")
+
+// RequireAuthForReadsWithOptions requires an authenticated principal on GET/HEAD
+// requests (writes are forwarded straight to the cxdb backend, which does its own
+// authorization) and attaches the resolved Session to the request context via
+// WithUser whenever one can be resolved, regardless of method — this lets downstream
+// middleware (per-user rate limiting, audit logging) see who made a write without the
+// gateway itself requiring a session for it. Authentication is resolved in order: a
+// DevBypass escape hatch, then each configured TokenVerifier against an Authorization:
+// Bearer header, then the session cookie via opts.Encoder. A cookie-backed session has
+// its access token transparently refreshed (via Session.AccessToken) before it's
+// attached, so long-lived browser sessions don't force re-login every time the
+// upstream access token expires.
+func RequireAuthForReadsWithOptions(opts AuthMiddlewareOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.DevBypass {
+			next.ServeHTTP(w, r)
+			return
+		}
+		requiresAuth := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+		if sess := verifyBearerToken(r, opts.TokenVerifiers); sess != nil {
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), sess)))
+			return
+		}
+
+		sess, err := opts.Encoder.Read(r.Context(), r)
+		if err != nil && middlewareDebug {
+			log.Printf("[auth] session lookup failed: %v", err)
+		}
+		if sess == nil {
+			if requiresAuth {
+				http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		before := sess.accessToken
+		if _, err := sess.AccessToken(r.Context()); err != nil && middlewareDebug {
+			// A refresh failure doesn't invalidate the session by itself: the caller may
+			// still be using the (possibly stale) access token for nothing at all, e.g. a
+			// session created by a provider with no refresher. Only log it.
+			log.Printf("[auth] transparent access token refresh failed for session %s: %v", sess.ID, err)
+		} else if sess.accessToken != before {
+			// The refresh rotated the access (and maybe refresh) token. For ServerStore
+			// sessions that's already durable in SQLite via the background sweep in
+			// refresh.go, but CookieStore sessions have no server-side row at all - the
+			// sealed cookie itself IS the only copy, so it must be re-issued or the
+			// refreshed token is lost the moment this response is written.
+			if err := opts.Encoder.Issue(r.Context(), w, sess); err != nil && middlewareDebug {
+				log.Printf("[auth] re-issuing session %s after token refresh failed: %v", sess.ID, err)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), sess)))
+	})
+}
+
+func verifyBearerToken(r *http.Request, verifiers []BearerTokenVerifier) *Session {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	for _, v := range verifiers {
+		if sess, err := v.Verify(token); err == nil && sess != nil {
+			return sess
+		}
+	}
+	return nil
+}
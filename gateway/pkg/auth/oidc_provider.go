@@ -0,0 +1,364 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// oidcDiscovery is the subset of the /.well-known/openid-configuration document that
+// OIDCProvider needs to drive an authorization-code + PKCE flow.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider implements Provider for any issuer that exposes a standard
+// /.well-known/openid-configuration document: Keycloak, Dex, Okta, Azure AD, etc.
+type OIDCProvider struct {
+	id            string
+	issuerURL     string
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	scopes        []string
+	allowedDomain string
+	sessionSecret string
+	sessions      *SessionStore
+	encoder       SessionEncoder
+
+	discovery oidcDiscovery
+
+	keySetMu    sync.RWMutex
+	keySet      jwk.Set
+	lastRefresh time.Time
+}
+
+// NewOIDCProvider fetches issuerURL's discovery document and returns a Provider that
+// mounts under /auth/<id>/{login,callback}. allowedDomain, when non-empty, restricts
+// logins to emails ending in "@"+allowedDomain, same as GoogleAuth.
+func NewOIDCProvider(id, issuerURL, clientID, clientSecret, publicBaseURL string, scopes []string, allowedDomain, sessionSecret string, sessions *SessionStore, encoder SessionEncoder) (*OIDCProvider, error) {
+	p := &OIDCProvider{
+		id:            id,
+		issuerURL:     strings.TrimSuffix(issuerURL, "/"),
+		clientID:      clientID,
+		clientSecret:  clientSecret,
+		redirectURL:   strings.TrimSuffix(publicBaseURL, "/") + "/auth/" + id + "/callback",
+		scopes:        scopes,
+		allowedDomain: strings.ToLower(strings.TrimSpace(allowedDomain)),
+		sessionSecret: sessionSecret,
+		sessions:      sessions,
+		encoder:       encoder,
+	}
+	if len(p.scopes) == 0 {
+		p.scopes = []string{"openid", "email", "profile"}
+	}
+	if err := p.fetchDiscovery(context.Background()); err != nil {
+		return nil, fmt.Errorf("oidc discovery for %s: %w", id, err)
+	}
+	if err := p.refreshKeySet(context.Background()); err != nil {
+		return nil, fmt.Errorf("oidc jwks for %s: %w", id, err)
+	}
+	return p, nil
+}
+
+func (p *OIDCProvider) ID() string { return p.id }
+
+// FormActionHosts returns the host of the provider's authorization endpoint, as
+// discovered from its /.well-known/openid-configuration document (Keycloak realm
+// URL, Okta domain, etc).
+func (p *OIDCProvider) FormActionHosts() []string {
+	u, err := url.Parse(p.discovery.AuthorizationEndpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil
+	}
+	return []string{u.Scheme + "://" + u.Host}
+}
+
+func (p *OIDCProvider) fetchDiscovery(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch discovery: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("discovery returned %d", resp.StatusCode)
+	}
+	var d oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return fmt.Errorf("decode discovery: %w", err)
+	}
+	p.discovery = d
+	return nil
+}
+
+func (p *OIDCProvider) refreshKeySet(ctx context.Context) error {
+	keySet, err := jwk.Fetch(ctx, p.discovery.JWKSURI)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	p.keySetMu.Lock()
+	p.keySet = keySet
+	p.lastRefresh = time.Now()
+	p.keySetMu.Unlock()
+	return nil
+}
+
+func (p *OIDCProvider) currentKeySet(ctx context.Context) jwk.Set {
+	p.keySetMu.RLock()
+	stale := time.Since(p.lastRefresh) > time.Hour
+	keySet := p.keySet
+	p.keySetMu.RUnlock()
+	if stale {
+		if err := p.refreshKeySet(ctx); err != nil {
+			log.Printf("[oidc:%s] jwks refresh failed: %v", p.id, err)
+		} else {
+			p.keySetMu.RLock()
+			keySet = p.keySet
+			p.keySetMu.RUnlock()
+		}
+	}
+	return keySet
+}
+
+// Login redirects to the provider's authorization endpoint with PKCE and a nonce.
+func (p *OIDCProvider) Login(w http.ResponseWriter, r *http.Request) {
+	st, err := newAuthState(true, true)
+	if err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+	if err := setStateCookie(w, p.sessions, p.sessionSecret, st, 10*time.Minute); err != nil {
+		http.Error(w, "unable to create state", http.StatusInternalServerError)
+		return
+	}
+
+	q := url.Values{
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {st.State},
+		"nonce":                 {st.Nonce},
+		"code_challenge":        {pkceChallenge(st.CodeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	http.Redirect(w, r, p.discovery.AuthorizationEndpoint+"?"+q.Encode(), http.StatusFound)
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+}
+
+// Callback exchanges the code (with the PKCE verifier) for tokens, verifies the
+// id_token (issuer, audience, nonce), and issues a session.
+func (p *OIDCProvider) Callback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		http.Redirect(w, r, "/login?error=access_denied", http.StatusFound)
+		return
+	}
+
+	st, ok := readStateCookie(r, p.sessionSecret, state)
+	if !ok {
+		http.Redirect(w, r, "/login?error=state", http.StatusFound)
+		return
+	}
+	clearStateCookie(w, p.sessions)
+
+	tok, err := p.exchangeCode(ctx, code, st.CodeVerifier)
+	if err != nil {
+		if p.sessions.Debug() {
+			log.Printf("[oidc:%s] exchange error: %v", p.id, err)
+		}
+		http.Redirect(w, r, "/login?error=exchange", http.StatusFound)
+		return
+	}
+
+	sess, err := p.verifyIDToken(ctx, tok.IDToken, st.Nonce)
+	if err != nil {
+		if p.sessions.Debug() {
+			log.Printf("[oidc:%s] id_token verify error: %v", p.id, err)
+		}
+		http.Redirect(w, r, "/login?error=profile", http.StatusFound)
+		return
+	}
+
+	if p.allowedDomain != "" && !strings.HasSuffix(sess.Email, "@"+p.allowedDomain) {
+		http.Redirect(w, r, "/login?error=unauthorized", http.StatusFound)
+		return
+	}
+
+	sess.Provider = p.id
+	sess.accessToken = tok.AccessToken
+	sess.refreshToken = tok.RefreshToken
+	sess.accessTokenExpiresAt = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	sess.refresher = p.Refresh
+
+	if err := p.encoder.Issue(ctx, w, sess); err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (p *OIDCProvider) exchangeCode(ctx context.Context, code, codeVerifier string) (*oidcTokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	return p.postToken(ctx, form)
+}
+
+func (p *OIDCProvider) postToken(ctx context.Context, form url.Values) (*oidcTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+	var tok oidcTokenResponse
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	return &tok, nil
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, wantNonce string) (*Session, error) {
+	return p.Verify(ctx, idToken, wantNonce)
+}
+
+// Verify validates idToken's signature, issuer, audience, and (when wantNonce is
+// non-empty) nonce claim, returning the Session it describes.
+func (p *OIDCProvider) Verify(ctx context.Context, idToken, wantNonce string) (*Session, error) {
+	token, err := jwt.Parse([]byte(idToken),
+		jwt.WithKeySet(p.currentKeySet(ctx)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(p.discovery.Issuer),
+		jwt.WithAudience(p.clientID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if wantNonce != "" {
+		v, ok := token.Get("nonce")
+		nonce, _ := v.(string)
+		if !ok || nonce != wantNonce {
+			return nil, fmt.Errorf("nonce mismatch")
+		}
+	}
+	email, _ := claimString(token, "email")
+	name, _ := claimString(token, "name")
+	picture, _ := claimString(token, "picture")
+	groups := claimStringSlice(token, "groups")
+	if email == "" {
+		email = token.Subject()
+	}
+	if name == "" {
+		name = email
+	}
+	now := time.Now()
+	return &Session{
+		Email:     strings.ToLower(email),
+		Name:      name,
+		Picture:   picture,
+		Provider:  p.id,
+		Groups:    groups,
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.sessions.ttl),
+	}, nil
+}
+
+// claimString returns the string value of an id_token claim, or "" if the claim is
+// absent or not a string.
+func claimString(token jwt.Token, name string) (string, bool) {
+	v, ok := token.Get(name)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// claimStringSlice returns the string-slice value of an id_token claim (e.g. "groups"),
+// tolerating the []interface{} shape encoding/json produces for untyped claims.
+func claimStringSlice(token jwt.Token, name string) []string {
+	v, ok := token.Get(name)
+	if !ok {
+		return nil
+	}
+	switch vv := v.(type) {
+	case []string:
+		return vv
+	case []interface{}:
+		out := make([]string, 0, len(vv))
+		for _, e := range vv {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Refresh exchanges refreshToken for a fresh access token via the token endpoint.
+func (p *OIDCProvider) Refresh(ctx context.Context, refreshToken string) (string, time.Time, string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.clientID},
+		"client_secret": {p.clientSecret},
+	}
+	tok, err := p.postToken(ctx, form)
+	if err != nil {
+		return "", time.Time{}, "", fmt.Errorf("refresh %s token: %w", p.id, err)
+	}
+	newRefresh := tok.RefreshToken
+	if newRefresh == refreshToken {
+		newRefresh = ""
+	}
+	return tok.AccessToken, time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second), newRefresh, nil
+}
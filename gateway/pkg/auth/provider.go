@@ -0,0 +1,134 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Provider is an OIDC-style identity provider that can be mounted under
+// /auth/<ID()>/login and /auth/<ID()>/callback by a ProviderRegistry.
+type Provider interface {
+	// ID is the short, URL-safe identifier used in route paths and Session.Provider,
+	// e.g. "google", "keycloak", "github".
+	ID() string
+	// Login redirects the browser to the provider's consent screen.
+	Login(w http.ResponseWriter, r *http.Request)
+	// Callback completes the code exchange, issues a session, and redirects home.
+	Callback(w http.ResponseWriter, r *http.Request)
+	// Refresh exchanges a refresh token for a fresh access token. Providers that don't
+	// issue refresh tokens (GitHub classic OAuth apps) return an error.
+	Refresh(ctx context.Context, refreshToken string) (accessToken string, expiresAt time.Time, newRefreshToken string, err error)
+	// Verify validates a raw ID token issued by this provider (nonce included, when the
+	// provider supports one) and returns the Session it describes. Providers without
+	// ID tokens (GitHub, Bitbucket) return an error.
+	Verify(ctx context.Context, idToken, nonce string) (*Session, error)
+	// FormActionHosts lists the origins the browser is redirected to as part of this
+	// provider's login flow (e.g. "https://accounts.google.com"), for the CSP
+	// form-action directive. The gateway only needs to loosen that directive for
+	// providers that are actually enabled.
+	FormActionHosts() []string
+}
+
+var (
+	_ Provider = (*GoogleAuth)(nil)
+	_ Provider = (*OIDCProvider)(nil)
+	_ Provider = (*GitHubProvider)(nil)
+	_ Provider = (*BitbucketProvider)(nil)
+)
+
+// ProviderRegistry mounts one or more Provider implementations under
+// /auth/<id>/login and /auth/<id>/callback, and exposes a LogoutHandler shared across
+// all of them since logout only needs the session encoder, not the provider.
+type ProviderRegistry struct {
+	providers map[string]Provider
+	order     []string
+	sessions  *SessionStore
+	encoder   SessionEncoder
+}
+
+// NewProviderRegistry builds an empty registry backed by sessions/encoder. Use
+// Register to add providers before calling Mount.
+func NewProviderRegistry(sessions *SessionStore, encoder SessionEncoder) *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]Provider),
+		sessions:  sessions,
+		encoder:   encoder,
+	}
+}
+
+// Register adds p to the registry. Registering two providers with the same ID panics
+// at startup, the same way a duplicate http.ServeMux pattern would.
+func (reg *ProviderRegistry) Register(p Provider) {
+	if _, exists := reg.providers[p.ID()]; exists {
+		panic("auth: provider " + p.ID() + " registered twice")
+	}
+	reg.providers[p.ID()] = p
+	reg.order = append(reg.order, p.ID())
+}
+
+// Lookup returns the provider registered under id, or nil.
+func (reg *ProviderRegistry) Lookup(id string) Provider {
+	return reg.providers[id]
+}
+
+// IDs returns the registered provider IDs in registration order.
+func (reg *ProviderRegistry) IDs() []string {
+	return append([]string(nil), reg.order...)
+}
+
+// Encoder returns the SessionEncoder backing every provider in this registry, so
+// callers that only have the registry (e.g. the auth middleware) can read back a
+// session the same way Login/Callback issued it, regardless of whether sessions are
+// server-side (ServerStore) or sealed into the cookie itself (CookieStore).
+func (reg *ProviderRegistry) Encoder() SessionEncoder {
+	return reg.encoder
+}
+
+// Mount registers /auth/<id>/login and /auth/<id>/callback for every provider, plus a
+// single shared /auth/logout that clears the session regardless of which provider
+// issued it.
+func (reg *ProviderRegistry) Mount(mux *http.ServeMux) {
+	for _, id := range reg.order {
+		p := reg.providers[id]
+		mux.HandleFunc("/auth/"+id+"/login", p.Login)
+		mux.HandleFunc("/auth/"+id+"/callback", p.Callback)
+	}
+	mux.HandleFunc("/auth/logout", reg.LogoutHandler)
+}
+
+// LogoutHandler clears the session cookie(s) and redirects to login, regardless of
+// which provider created the session.
+func (reg *ProviderRegistry) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	_ = reg.encoder.Clear(r.Context(), w, r)
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+// FormActionHosts collects the deduplicated CSP form-action hosts needed by every
+// registered provider, in registration order.
+func (reg *ProviderRegistry) FormActionHosts() []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, id := range reg.order {
+		for _, host := range reg.providers[id].FormActionHosts() {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+// RefresherSet builds a RefresherSet covering every registered provider, for
+// SessionStore.StartRefresher.
+func (reg *ProviderRegistry) RefresherSet() RefresherSet {
+	set := make(RefresherSet, len(reg.providers))
+	for id, p := range reg.providers {
+		set[id] = p.Refresh
+	}
+	return set
+}
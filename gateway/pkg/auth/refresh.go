@@ -0,0 +1,122 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// refreshSkew is how far ahead of expiry we proactively refresh an access token.
+const refreshSkew = 2 * time.Minute
+
+// AccessToken returns a valid OAuth2 access token for the session, transparently
+// refreshing it first if it is within refreshSkew of expiring. Sessions that were not
+// created by a token-issuing provider (K8s OIDC, AWS IAM) have no refresher and return
+// the empty string.
+func (s *Session) AccessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refresher == nil {
+		return s.accessToken, nil
+	}
+	if s.accessToken != "" && time.Until(s.accessTokenExpiresAt) > refreshSkew {
+		return s.accessToken, nil
+	}
+	if s.refreshToken == "" {
+		return "", fmt.Errorf("session %s has no refresh token", s.ID)
+	}
+
+	accessToken, expiresAt, newRefreshToken, err := s.refresher(ctx, s.refreshToken)
+	if err != nil {
+		return "", fmt.Errorf("refresh access token: %w", err)
+	}
+	s.accessToken = accessToken
+	s.accessTokenExpiresAt = expiresAt
+	if newRefreshToken != "" {
+		s.refreshToken = newRefreshToken
+	}
+	return s.accessToken, nil
+}
+
+// RefresherSet resolves the RefreshFunc for a session's Provider id, e.g. "google".
+// Callers populate one entry per provider they register.
+type RefresherSet map[string]RefreshFunc
+
+// StartRefresher runs a background goroutine that proactively refreshes every stored
+// session's access token shortly before it expires, so AccessToken(ctx) rarely blocks
+// a request on a live token exchange. It stops when ctx is done.
+func (s *SessionStore) StartRefresher(ctx context.Context, interval time.Duration, refreshers RefresherSet) {
+	go s.refreshLoop(ctx, interval, refreshers)
+}
+
+func (s *SessionStore) refreshLoop(ctx context.Context, interval time.Duration, refreshers RefresherSet) {
+	for {
+		jitter := time.Duration(rand.Int63n(int64(interval) / 4))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval + jitter):
+		}
+		if err := s.refreshDueSessions(ctx, refreshers); err != nil && s.debug {
+			log.Printf("[auth] session refresh sweep failed: %v", err)
+		}
+	}
+}
+
+func (s *SessionStore) refreshDueSessions(ctx context.Context, refreshers RefresherSet) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id FROM sessions WHERE refresh_token != '' AND access_token_expires_at < ?`,
+		time.Now().Add(refreshSkew).Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("query due sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+
+	for _, id := range ids {
+		sess, err := s.Get(ctx, id)
+		if err != nil || sess == nil {
+			continue
+		}
+		refresher, ok := refreshers[sess.Provider]
+		if !ok {
+			continue
+		}
+		sess.refresher = refresher
+		if _, err := sess.AccessToken(ctx); err != nil {
+			if s.debug {
+				log.Printf("[auth] background refresh failed for session %s: %v", sess.ID, err)
+			}
+			continue
+		}
+		if err := s.updateTokens(ctx, sess); err != nil && s.debug {
+			log.Printf("[auth] persisting refreshed tokens failed for session %s: %v", sess.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *SessionStore) updateTokens(ctx context.Context, sess *Session) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sessions SET access_token = ?, refresh_token = ?, access_token_expires_at = ? WHERE id = ?`,
+		sess.accessToken, sess.refreshToken, sess.accessTokenExpiresAt.Unix(), sess.ID,
+	)
+	return err
+}
@@ -0,0 +1,300 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionEncoder issues and reads back the session for a request, independent of
+// whether the session state lives server-side (ServerStore) or entirely in the
+// browser (CookieStore). GoogleAuth and other providers are constructed with one.
+type SessionEncoder interface {
+	// Issue persists/encodes sess and writes whatever cookie(s) represent it.
+	Issue(ctx context.Context, w http.ResponseWriter, sess *Session) error
+	// Read recovers the session for the current request, or (nil, nil) if absent.
+	Read(ctx context.Context, r *http.Request) (*Session, error)
+	// Clear removes the session and its cookie(s).
+	Clear(ctx context.Context, w http.ResponseWriter, r *http.Request) error
+}
+
+// ServerStore is the original opaque-session-ID mode: state lives in SessionStore's
+// SQLite database and the cookie only carries the session ID.
+type ServerStore struct {
+	store *SessionStore
+}
+
+// NewServerStore wraps store as a SessionEncoder.
+func NewServerStore(store *SessionStore) *ServerStore {
+	return &ServerStore{store: store}
+}
+
+func (s *ServerStore) Issue(ctx context.Context, w http.ResponseWriter, sess *Session) error {
+	id, err := s.store.create(ctx, sess.Email, sess.Name, sess.Picture, sess.Provider, sess.Groups, sess.accessToken, sess.refreshToken, sess.accessTokenExpiresAt)
+	if err != nil {
+		return err
+	}
+	sess.ID = id
+	s.store.SetCookie(w, id)
+	return nil
+}
+
+func (s *ServerStore) Read(ctx context.Context, r *http.Request) (*Session, error) {
+	return s.store.SessionFromRequest(ctx, r)
+}
+
+func (s *ServerStore) Clear(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if sess, _ := s.store.SessionFromRequest(ctx, r); sess != nil {
+		if err := s.store.Delete(ctx, sess.ID); err != nil {
+			return err
+		}
+	}
+	s.store.ClearCookie(w)
+	return nil
+}
+
+// cookieMaxChunk is the payload size per numbered cookie, kept well under the ~4KB
+// per-cookie limit most browsers enforce once the Set-Cookie attributes are counted.
+const cookieMaxChunk = 3500
+
+// CookieStore encodes the entire session (including encrypted access/refresh tokens)
+// into the browser's cookie jar instead of a server-side table. The encoded payload is
+// AEAD-sealed with AES-GCM under a rotating key ring so old cookies remain decryptable
+// across key rotations, and split across numbered cookies when it doesn't fit in one.
+type CookieStore struct {
+	name       string
+	domain     string
+	secure     bool
+	ttl        int
+	keyRing    *keyRing
+	refreshers RefresherSet
+}
+
+// NewCookieStore builds a CookieStore that encrypts sessions with keys (newest first;
+// only keys[0] is used to encrypt, all are tried on decrypt so rotation doesn't log
+// everyone out).
+func NewCookieStore(cookieName, domain string, secure bool, ttlSeconds int, keys ...string) (*CookieStore, error) {
+	ring, err := newKeyRing(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{name: cookieName, domain: domain, secure: secure, ttl: ttlSeconds, keyRing: ring}, nil
+}
+
+// SetRefreshers wires the provider RefreshFuncs into the store so sessions it decodes
+// via Read can transparently refresh their access token (CookieStore sessions never
+// touch SessionStore's SQLite table, so SessionStore.StartRefresher's background sweep
+// never sees them - this is the only refresh path they get). Callers set this once
+// every provider has been registered.
+func (c *CookieStore) SetRefreshers(refreshers RefresherSet) {
+	c.refreshers = refreshers
+}
+
+type cookiePayload struct {
+	Email                string   `json:"email"`
+	Name                 string   `json:"name"`
+	Picture              string   `json:"picture"`
+	Provider             string   `json:"provider"`
+	Groups               []string `json:"groups,omitempty"`
+	CreatedAtUnix        int64    `json:"created_at"`
+	ExpiresAtUnix        int64    `json:"expires_at"`
+	AccessToken          string   `json:"access_token,omitempty"`
+	RefreshToken         string   `json:"refresh_token,omitempty"`
+	AccessTokenExpiresAt int64    `json:"access_token_expires_at,omitempty"`
+}
+
+func (c *CookieStore) Issue(ctx context.Context, w http.ResponseWriter, sess *Session) error {
+	p := cookiePayload{
+		Email:                sess.Email,
+		Name:                 sess.Name,
+		Picture:              sess.Picture,
+		Provider:             sess.Provider,
+		Groups:               sess.Groups,
+		CreatedAtUnix:        sess.CreatedAt.Unix(),
+		ExpiresAtUnix:        sess.ExpiresAt.Unix(),
+		AccessToken:          sess.accessToken,
+		RefreshToken:         sess.refreshToken,
+		AccessTokenExpiresAt: sess.accessTokenExpiresAt.Unix(),
+	}
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	sealed, err := c.keyRing.seal(plaintext)
+	if err != nil {
+		return fmt.Errorf("seal session: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	c.writeChunks(w, encoded)
+	return nil
+}
+
+func (c *CookieStore) writeChunks(w http.ResponseWriter, encoded string) {
+	chunks := splitChunks(encoded, cookieMaxChunk)
+	for i, chunk := range chunks {
+		http.SetCookie(w, &http.Cookie{
+			Name:     c.chunkName(i),
+			Value:    chunk,
+			Domain:   c.domain,
+			Path:     "/",
+			MaxAge:   c.ttl,
+			HttpOnly: true,
+			Secure:   c.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	// Clear any leftover chunks from a previously larger session payload.
+	for i := len(chunks); i < len(chunks)+4; i++ {
+		http.SetCookie(w, &http.Cookie{
+			Name: c.chunkName(i), Value: "", Domain: c.domain, Path: "/", MaxAge: -1, HttpOnly: true, Secure: c.secure,
+		})
+	}
+}
+
+func (c *CookieStore) chunkName(i int) string {
+	if i == 0 {
+		return c.name
+	}
+	return c.name + "_" + strconv.Itoa(i)
+}
+
+func (c *CookieStore) Read(ctx context.Context, r *http.Request) (*Session, error) {
+	var b strings.Builder
+	for i := 0; ; i++ {
+		ck, err := r.Cookie(c.chunkName(i))
+		if err != nil {
+			break
+		}
+		b.WriteString(ck.Value)
+	}
+	if b.Len() == 0 {
+		return nil, nil
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(b.String())
+	if err != nil {
+		return nil, nil
+	}
+	plaintext, err := c.keyRing.open(sealed)
+	if err != nil {
+		return nil, nil
+	}
+	var p cookiePayload
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, nil
+	}
+	sess := &Session{
+		Email:                p.Email,
+		Name:                 p.Name,
+		Picture:              p.Picture,
+		Provider:             p.Provider,
+		Groups:               p.Groups,
+		accessToken:          p.AccessToken,
+		refreshToken:         p.RefreshToken,
+		accessTokenExpiresAt: time.Unix(p.AccessTokenExpiresAt, 0),
+		CreatedAt:            time.Unix(p.CreatedAtUnix, 0),
+		ExpiresAt:            time.Unix(p.ExpiresAtUnix, 0),
+	}
+	if sess.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	sess.refresher = c.refreshers[sess.Provider]
+	return sess, nil
+}
+
+func (c *CookieStore) Clear(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	// Clear cookie 0 unconditionally, plus every numbered chunk actually present.
+	http.SetCookie(w, &http.Cookie{
+		Name: c.chunkName(0), Value: "", Domain: c.domain, Path: "/", MaxAge: -1, HttpOnly: true, Secure: c.secure,
+	})
+	for i := 1; ; i++ {
+		if _, err := r.Cookie(c.chunkName(i)); err != nil {
+			break
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name: c.chunkName(i), Value: "", Domain: c.domain, Path: "/", MaxAge: -1, HttpOnly: true, Secure: c.secure,
+		})
+	}
+	return nil
+}
+
+func splitChunks(s string, size int) []string {
+	if len(s) <= size {
+		return []string{s}
+	}
+	var chunks []string
+	for len(s) > 0 {
+		n := size
+		if n > len(s) {
+			n = len(s)
+		}
+		chunks = append(chunks, s[:n])
+		s = s[n:]
+	}
+	return chunks
+}
+
+// keyRing AEAD-seals/opens payloads with AES-256-GCM. The first key encrypts; every
+// key is tried on decrypt so a rotation doesn't invalidate cookies already in flight.
+type keyRing struct {
+	gcms []cipher.AEAD
+}
+
+func newKeyRing(secrets []string) (*keyRing, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("cookie session store requires at least one encryption key")
+	}
+	r := &keyRing{}
+	for _, secret := range secrets {
+		sum := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return nil, fmt.Errorf("build AES cipher: %w", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("build AES-GCM: %w", err)
+		}
+		r.gcms = append(r.gcms, gcm)
+	}
+	return r, nil
+}
+
+func (k *keyRing) seal(plaintext []byte) ([]byte, error) {
+	gcm := k.gcms[0]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (k *keyRing) open(data []byte) ([]byte, error) {
+	var lastErr error
+	for _, gcm := range k.gcms {
+		ns := gcm.NonceSize()
+		if len(data) < ns {
+			lastErr = errors.New("ciphertext shorter than nonce")
+			continue
+		}
+		nonce, ciphertext := data[:ns], data[ns:]
+		plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+		if err == nil {
+			return plaintext, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("decrypt with any key in ring: %w", lastErr)
+}
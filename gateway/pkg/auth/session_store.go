@@ -0,0 +1,207 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Session represents an authenticated principal, whether created by an OAuth2/OIDC
+// login flow, a K8s service account JWT, or an AWS IAM token exchange.
+type Session struct {
+	ID        string
+	Email     string
+	Name      string
+	Picture   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+
+	// Provider is the id of the auth.Provider that created this session (e.g. "google"),
+	// empty for bearer-token sessions (K8s OIDC, AWS IAM).
+	Provider string
+
+	// Groups is whatever group/role claim the provider exposed (e.g. an OIDC "groups"
+	// claim, or a K8s service account's namespace), forwarded to the backend as
+	// X-Auth-Request-Groups. Providers that don't expose one leave this nil.
+	Groups []string
+
+	mu                   sync.Mutex
+	accessToken          string
+	refreshToken         string
+	accessTokenExpiresAt time.Time
+	refresher            RefreshFunc
+}
+
+// RefreshFunc exchanges a refresh token for a fresh access token. Providers set this
+// on the sessions they mint so Session.AccessToken can refresh on demand without the
+// session package knowing about oauth2.Config or any particular IdP.
+type RefreshFunc func(ctx context.Context, refreshToken string) (accessToken string, expiresAt time.Time, newRefreshToken string, err error)
+
+// SessionStore persists server-side sessions in SQLite and manages the session cookie.
+type SessionStore struct {
+	db         *sql.DB
+	cookieName string
+	ttl        time.Duration
+	domain     string
+	secure     bool
+	secret     string
+	debug      bool
+}
+
+// NewSessionStore opens (or creates) the session database at dbPath and returns a store
+// that issues cookies named cookieName with the given TTL, domain, and secure flag.
+func NewSessionStore(dbPath, cookieName string, ttl time.Duration, domain string, secure bool, secret string) (*SessionStore, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open session db: %w", err)
+	}
+	const schema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	email TEXT NOT NULL,
+	name TEXT NOT NULL,
+	picture TEXT,
+	provider TEXT,
+	groups TEXT,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL,
+	access_token TEXT,
+	refresh_token TEXT,
+	access_token_expires_at INTEGER
+);`
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate session db: %w", err)
+	}
+	return &SessionStore{
+		db:         db,
+		cookieName: cookieName,
+		ttl:        ttl,
+		domain:     domain,
+		secure:     secure,
+		secret:     secret,
+		debug:      strings.Contains(os.Getenv("DEBUG"), "auth") || strings.Contains(os.Getenv("DEBUG"), "all"),
+	}, nil
+}
+
+// Create inserts a new session for a provider login and returns its ID.
+func (s *SessionStore) Create(ctx context.Context, email, name, picture string) (string, error) {
+	return s.create(ctx, email, name, picture, "", nil, "", "", time.Time{})
+}
+
+func (s *SessionStore) create(ctx context.Context, email, name, picture, provider string, groups []string, accessToken, refreshToken string, accessTokenExpiresAt time.Time) (string, error) {
+	id, err := randomState()
+	if err != nil {
+		return "", fmt.Errorf("generate session id: %w", err)
+	}
+	now := time.Now()
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO sessions (id, email, name, picture, provider, groups, created_at, expires_at, access_token, refresh_token, access_token_expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, email, name, picture, provider, strings.Join(groups, ","), now.Unix(), now.Add(s.ttl).Unix(), accessToken, refreshToken, accessTokenExpiresAt.Unix(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("insert session: %w", err)
+	}
+	return id, nil
+}
+
+// Get loads a session by ID, returning nil if it does not exist or has expired.
+func (s *SessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	var sess Session
+	var createdAt, expiresAt, accessExpiresAt int64
+	var accessToken, refreshToken, groups sql.NullString
+	row := s.db.QueryRowContext(ctx,
+		`SELECT id, email, name, picture, provider, groups, created_at, expires_at, access_token, refresh_token, access_token_expires_at
+		 FROM sessions WHERE id = ?`, id)
+	if err := row.Scan(&sess.ID, &sess.Email, &sess.Name, &sess.Picture, &sess.Provider, &groups, &createdAt, &expiresAt, &accessToken, &refreshToken, &accessExpiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("load session: %w", err)
+	}
+	sess.CreatedAt = time.Unix(createdAt, 0)
+	sess.ExpiresAt = time.Unix(expiresAt, 0)
+	sess.accessToken = accessToken.String
+	sess.refreshToken = refreshToken.String
+	sess.accessTokenExpiresAt = time.Unix(accessExpiresAt, 0)
+	if groups.String != "" {
+		sess.Groups = strings.Split(groups.String, ",")
+	}
+	if sess.ExpiresAt.Before(time.Now()) {
+		return nil, nil
+	}
+	return &sess, nil
+}
+
+// Delete removes a session.
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+// SessionFromRequest loads the session named by the store's cookie, if any.
+func (s *SessionStore) SessionFromRequest(ctx context.Context, r *http.Request) (*Session, error) {
+	c, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return nil, nil
+	}
+	return s.Get(ctx, c.Value)
+}
+
+// SetCookie writes the session cookie for id.
+func (s *SessionStore) SetCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    id,
+		Domain:   s.domain,
+		Path:     "/",
+		MaxAge:   int(s.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearCookie removes the session cookie from the browser.
+func (s *SessionStore) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookieName,
+		Value:    "",
+		Domain:   s.domain,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+// Domain returns the cookie domain configured for this store.
+func (s *SessionStore) Domain() string { return s.domain }
+
+// Secure returns whether cookies issued by this store set the Secure flag.
+func (s *SessionStore) Secure() bool { return s.secure }
+
+// Debug reports whether auth debug logging is enabled via the DEBUG env var.
+func (s *SessionStore) Debug() bool { return s.debug }
+
+// Ping verifies the underlying database is reachable.
+func (s *SessionStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close releases the underlying database handle.
+func (s *SessionStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// subtleEqual compares two state tokens in constant time so a timing side-channel
+// can't be used to guess a valid oauth_state value.
+func subtleEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// authState is the payload carried by the signed "oauth_state" cookie across the
+// redirect to the IdP and back. Every provider (Google, generic OIDC, GitHub,
+// Bitbucket) shares this cookie so the PKCE code_verifier and OIDC nonce travel with
+// the CSRF state token instead of needing separate cookies per provider.
+type authState struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	Nonce        string `json:"nonce,omitempty"`
+}
+
+const stateCookieName = "oauth_state"
+
+// newAuthState generates a fresh state token, and a PKCE code_verifier/nonce when
+// withPKCE/withNonce are set.
+func newAuthState(withPKCE, withNonce bool) (authState, error) {
+	state, err := randomState()
+	if err != nil {
+		return authState{}, err
+	}
+	st := authState{State: state}
+	if withPKCE {
+		verifier, err := randomState()
+		if err != nil {
+			return authState{}, err
+		}
+		st.CodeVerifier = verifier
+	}
+	if withNonce {
+		nonce, err := randomState()
+		if err != nil {
+			return authState{}, err
+		}
+		st.Nonce = nonce
+	}
+	return st, nil
+}
+
+// pkceChallenge returns the S256 PKCE code_challenge for a code_verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// setStateCookie HMAC-signs st with secret and stores it as the oauth_state cookie,
+// scoped the same way the session cookie is (domain/secure from sessions).
+func setStateCookie(w http.ResponseWriter, sessions *SessionStore, secret string, st authState, maxAge time.Duration) error {
+	signed, err := signState(secret, st)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    signed,
+		Domain:   sessions.Domain(),
+		Path:     "/",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   sessions.Secure(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// readStateCookie verifies the oauth_state cookie's signature, and that its State
+// field matches the state query parameter returned by the IdP.
+func readStateCookie(r *http.Request, secret, wantState string) (authState, bool) {
+	if wantState == "" {
+		return authState{}, false
+	}
+	c, err := r.Cookie(stateCookieName)
+	if err != nil {
+		return authState{}, false
+	}
+	st, err := verifyState(secret, c.Value)
+	if err != nil {
+		return authState{}, false
+	}
+	if !subtleEqual(st.State, wantState) {
+		return authState{}, false
+	}
+	return st, true
+}
+
+func clearStateCookie(w http.ResponseWriter, sessions *SessionStore) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    "",
+		Domain:   sessions.Domain(),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   sessions.Secure(),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+func signState(secret string, st authState) (string, error) {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return "", fmt.Errorf("marshal state: %w", err)
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmacSum(secret, encodedPayload)
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+func verifyState(secret, signed string) (authState, error) {
+	dot := -1
+	for i := len(signed) - 1; i >= 0; i-- {
+		if signed[i] == '.' {
+			dot = i
+			break
+		}
+	}
+	if dot < 0 {
+		return authState{}, fmt.Errorf("malformed state cookie")
+	}
+	encodedPayload, encodedMAC := signed[:dot], signed[dot+1:]
+	gotMAC, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return authState{}, fmt.Errorf("decode state mac: %w", err)
+	}
+	wantMAC := hmacSum(secret, encodedPayload)
+	if !hmac.Equal(gotMAC, wantMAC) {
+		return authState{}, fmt.Errorf("state signature mismatch")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return authState{}, fmt.Errorf("decode state payload: %w", err)
+	}
+	var st authState
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return authState{}, fmt.Errorf("unmarshal state: %w", err)
+	}
+	return st, nil
+}
+
+func hmacSum(secret, msg string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+// auditMaxBodyBytesDefault caps how much of a request body AuditLogger buffers for an
+// audit entry when config doesn't override it. Bodies larger than the cap are
+// truncated for the entry only - the backend still receives the request in full.
+const auditMaxBodyBytesDefault = 16 * 1024
+
+// redactedPlaceholder replaces a redacted field's value in a captured audit body.
+const redactedPlaceholder = "[REDACTED]"
+
+// auditBodyReadCeiling hard-caps how much of a request body captureBody will ever read
+// into memory, regardless of maxBodyBytes. It's far above any legitimate write payload
+// but bounds the read itself, so an oversized/unbounded request body can't be used to
+// exhaust gateway memory just by being audited.
+const auditBodyReadCeiling = 32 * 1024 * 1024
+
+// AuditEntry is one record of a state-changing /v1/* request - the unit every
+// AuditSink persists.
+type AuditEntry struct {
+	Timestamp     time.Time       `json:"timestamp"`
+	RequestID     string          `json:"request_id"`
+	Method        string          `json:"method"`
+	Path          string          `json:"path"`
+	Status        int             `json:"status"`
+	DurationMS    int64           `json:"duration_ms"`
+	IP            string          `json:"ip"`
+	User          string          `json:"user,omitempty"`
+	SessionID     string          `json:"session_id,omitempty"`
+	Provider      string          `json:"provider,omitempty"`
+	RequestBody   json.RawMessage `json:"request_body,omitempty"`
+	BodyTruncated bool            `json:"body_truncated,omitempty"`
+}
+
+// AuditSink persists AuditEntry records somewhere durable - a file, stdout, or an
+// external collector. Write must not block the request any longer than necessary:
+// the webhook sink batches internally rather than posting from Write itself, while the
+// file sink appends each entry synchronously under its own lock.
+type AuditSink interface {
+	Write(entry AuditEntry)
+	// Close flushes any buffered entries and releases the sink's resources.
+	Close() error
+}
+
+// AuditLogger records request metadata, request bodies and response statuses for
+// state-changing /v1/* requests to one or more AuditSinks, with JSON-path redaction and a size cap on
+// the captured body. This is a compliance requirement for a gateway fronting a
+// database: loggingMiddleware's method/path/status access log can't reconstruct what a
+// write actually contained.
+type AuditLogger struct {
+	sinks        []AuditSink
+	logger       *slog.Logger
+	trusted      *trustedProxySet
+	maxBodyBytes int
+	redactPaths  [][]string
+}
+
+// NewAuditLogger builds an AuditLogger writing every entry to each of sinks.
+// redactPaths is a list of dotted JSON field paths (e.g. "user.password",
+// "credentials.apiKey") whose values are replaced with "[REDACTED]" in a captured
+// request body before it reaches any sink. A maxBodyBytes <= 0 falls back to
+// auditMaxBodyBytesDefault.
+func NewAuditLogger(sinks []AuditSink, maxBodyBytes int, redactPaths []string, trusted *trustedProxySet, logger *slog.Logger) *AuditLogger {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = auditMaxBodyBytesDefault
+	}
+	paths := make([][]string, 0, len(redactPaths))
+	for _, p := range redactPaths {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, strings.Split(p, "."))
+		}
+	}
+	return &AuditLogger{sinks: sinks, logger: logger, trusted: trusted, maxBodyBytes: maxBodyBytes, redactPaths: paths}
+}
+
+// Middleware audits every non-GET/HEAD request that reaches next, then forwards it
+// unchanged. Callers mount this only under /v1/* (see server.go), so in practice it
+// only ever sees proxied backend writes, but the method check makes it harmless to
+// mount anywhere. A correlation ID is read from X-Request-ID, generated if absent, and
+// echoed back on the response either way.
+func (a *AuditLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || len(a.sinks) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			id, err := newRequestID()
+			if err != nil {
+				a.logger.Error("audit request id generation failed", "err", err)
+			} else {
+				requestID = id
+			}
+		}
+		if requestID != "" {
+			r.Header.Set("X-Request-ID", requestID)
+			w.Header().Set("X-Request-ID", requestID)
+		}
+
+		body, truncated := a.captureBody(r)
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		entry := AuditEntry{
+			Timestamp:     start,
+			RequestID:     requestID,
+			Method:        r.Method,
+			Path:          r.URL.Path,
+			Status:        sw.status,
+			DurationMS:    time.Since(start).Milliseconds(),
+			IP:            clientIP(r, a.trusted),
+			RequestBody:   body,
+			BodyTruncated: truncated,
+		}
+		if sess := auth.UserFromContext(r.Context()); sess != nil {
+			entry.User = sess.Email
+			entry.SessionID = sess.ID
+			entry.Provider = sess.Provider
+		}
+		for _, sink := range a.sinks {
+			sink.Write(entry)
+		}
+	})
+}
+
+// captureBody buffers r's body (up to auditBodyReadCeiling; anything beyond that is
+// streamed through unread) so it can be replayed to the backend unmodified, and
+// returns a (capped, redacted) copy of up to maxBodyBytes for the audit entry.
+func (a *AuditLogger) captureBody(r *http.Request) (json.RawMessage, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, false
+	}
+	orig := r.Body
+	full, err := io.ReadAll(io.LimitReader(orig, auditBodyReadCeiling))
+	if err != nil {
+		_ = orig.Close()
+		r.Body = io.NopCloser(bytes.NewReader(nil))
+		a.logger.Error("audit body read failed", "path", r.URL.Path, "err", err)
+		return nil, false
+	}
+	if len(full) < auditBodyReadCeiling {
+		_ = orig.Close()
+		r.Body = io.NopCloser(bytes.NewReader(full))
+		r.ContentLength = int64(len(full))
+	} else {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(full), orig), orig}
+	}
+
+	captured := full
+	truncated := false
+	if len(captured) > a.maxBodyBytes {
+		captured = captured[:a.maxBodyBytes]
+		truncated = true
+	}
+	return a.redactBody(captured), truncated
+}
+
+// redactBody parses raw as JSON and blanks out a.redactPaths before re-encoding it. A
+// raw body that isn't valid JSON (including one truncated mid-object by the size cap)
+// is stored as an opaque string instead of being dropped, so the entry still records
+// that a write happened even when its body can't be parsed.
+func (a *AuditLogger) redactBody(raw []byte) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		b, err := json.Marshal(string(raw))
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+	for _, path := range a.redactPaths {
+		redactJSONPath(v, path)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// redactJSONPath walks v (a value decoded by encoding/json, so maps are
+// map[string]interface{}) along path, replacing the field at its end with
+// redactedPlaceholder wherever it's present. Paths only match through objects - arrays
+// aren't indexed into, since redaction paths name field names, not positions.
+func redactJSONPath(v interface{}, path []string) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		if _, exists := m[path[0]]; exists {
+			m[path[0]] = redactedPlaceholder
+		}
+		return
+	}
+	if next, ok := m[path[0]]; ok {
+		redactJSONPath(next, path[1:])
+	}
+}
+
+// Close flushes and closes every configured sink.
+func (a *AuditLogger) Close() error {
+	var firstErr error
+	for _, s := range a.sinks {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
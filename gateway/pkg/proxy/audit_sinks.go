@@ -0,0 +1,257 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// StdoutAuditSink writes one NDJSON line per AuditEntry to an io.Writer (os.Stdout in
+// production), for deployments that ship container logs to a collector rather than
+// writing to a local file or calling out to a webhook.
+type StdoutAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutAuditSink builds a StdoutAuditSink writing to os.Stdout.
+func NewStdoutAuditSink() *StdoutAuditSink {
+	return &StdoutAuditSink{w: os.Stdout}
+}
+
+func (s *StdoutAuditSink) Write(entry AuditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.w.Write(b)
+}
+
+func (s *StdoutAuditSink) Close() error { return nil }
+
+// FileAuditSink appends one NDJSON line per AuditEntry to a local file, rotating it
+// once it exceeds maxBytes. Only a single rotated generation is kept (path + ".1");
+// shipping or pruning older generations off-box is left to whatever log-shipping agent
+// already runs alongside the gateway.
+type FileAuditSink struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	f        *os.File
+	size     int64
+}
+
+// NewFileAuditSink opens (creating if necessary) the audit log file at path.
+// maxBytes <= 0 disables rotation.
+func NewFileAuditSink(path string, maxBytes int64) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("stat audit log file: %w", err)
+	}
+	return &FileAuditSink{path: path, maxBytes: maxBytes, f: f, size: info.Size()}, nil
+}
+
+func (s *FileAuditSink) Write(entry AuditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBytes > 0 && s.size+int64(len(b)) > s.maxBytes {
+		if err := s.rotateLocked(); err != nil {
+			return
+		}
+	}
+	n, err := s.f.Write(b)
+	if err != nil {
+		return
+	}
+	s.size += int64(n)
+}
+
+func (s *FileAuditSink) rotateLocked() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	rotated := s.path + ".1"
+	_ = os.Remove(rotated)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	s.size = 0
+	return nil
+}
+
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// webhookSinkHTTPTimeout bounds a single POST attempt to the audit webhook.
+const webhookSinkHTTPTimeout = 10 * time.Second
+
+// WebhookAuditSink batches audit entries and POSTs them as NDJSON to an external
+// collector, flushing whenever batchSize entries have queued or flushInterval elapses,
+// whichever comes first. A failed POST is retried with exponential backoff up to
+// maxRetries before the batch is dropped (and logged), so one unreachable collector
+// can't back request handling up indefinitely.
+type WebhookAuditSink struct {
+	url        string
+	batchSize  int
+	maxRetries int
+	client     *http.Client
+	logger     *slog.Logger
+
+	mu      sync.Mutex
+	pending []AuditEntry
+
+	flushCh chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewWebhookAuditSink builds a WebhookAuditSink posting to url and starts its
+// background flush loop.
+func NewWebhookAuditSink(url string, batchSize int, flushInterval time.Duration, maxRetries int, logger *slog.Logger) *WebhookAuditSink {
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	if flushInterval <= 0 {
+		flushInterval = 5 * time.Second
+	}
+	s := &WebhookAuditSink{
+		url:        url,
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		client:     &http.Client{Timeout: webhookSinkHTTPTimeout},
+		logger:     logger,
+		flushCh:    make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go s.loop(flushInterval)
+	return s
+}
+
+func (s *WebhookAuditSink) Write(entry AuditEntry) {
+	s.mu.Lock()
+	s.pending = append(s.pending, entry)
+	full := len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *WebhookAuditSink) loop(flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	defer close(s.stopped)
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.flushCh:
+			s.flush()
+		case <-s.done:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *WebhookAuditSink) flush() {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, e := range batch {
+		b, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if err := s.post(buf.Bytes()); err == nil {
+			return
+		} else if attempt >= s.maxRetries {
+			s.logger.Error("audit webhook delivery failed, dropping batch", "entries", len(batch), "err", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *WebhookAuditSink) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookSinkCloseTimeout bounds how long Close waits for the final flush (which may
+// itself retry with backoff) before giving up and returning anyway.
+const webhookSinkCloseTimeout = 5 * time.Second
+
+// Close stops the flush loop after a final flush of whatever is pending, waiting for
+// that flush to actually finish (up to webhookSinkCloseTimeout) so a graceful shutdown
+// doesn't race the process exiting before the last audit batch is POSTed.
+func (s *WebhookAuditSink) Close() error {
+	close(s.done)
+	select {
+	case <-s.stopped:
+	case <-time.After(webhookSinkCloseTimeout):
+		s.logger.Error("audit webhook close timed out waiting for final flush")
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ChangeSource produces a stream of context/turn change Events for SSEBroker to fan
+// out to subscribed clients. Implementations range from a dumb poller (the original
+// behavior, kept as a fallback) to database-native push notifications.
+type ChangeSource interface {
+	// Start begins producing events and returns the channel they arrive on. The
+	// channel is closed when ctx is done or the source gives up for good.
+	Start(ctx context.Context) (<-chan Event, error)
+}
+
+// PollingChangeSource is the original /v1/contexts?limit=50 poll loop, diffing
+// head_turn_id in-process. It's O(N·clients) and adds up to pollInterval of latency,
+// so it's kept only as the zero-config fallback when no push-based source is wired up.
+type PollingChangeSource struct {
+	backend      string
+	pollInterval time.Duration
+	logger       *slog.Logger
+	lastContexts map[string]contextState
+}
+
+type contextState struct {
+	HeadTurnID string `json:"head_turn_id"`
+	HeadDepth  int    `json:"head_depth"`
+}
+
+type contextsResponse struct {
+	Contexts []struct {
+		ContextID       string `json:"context_id"`
+		HeadTurnID      string `json:"head_turn_id"`
+		HeadDepth       int    `json:"head_depth"`
+		CreatedAtUnixMs int64  `json:"created_at_unix_ms"`
+	} `json:"contexts"`
+}
+
+// NewPollingChangeSource builds the fallback polling source against backendURL.
+func NewPollingChangeSource(backendURL string, pollInterval time.Duration, logger *slog.Logger) *PollingChangeSource {
+	return &PollingChangeSource{
+		backend:      backendURL,
+		pollInterval: pollInterval,
+		logger:       logger,
+		lastContexts: make(map[string]contextState),
+	}
+}
+
+func (s *PollingChangeSource) Start(ctx context.Context) (<-chan Event, error) {
+	out := make(chan Event, 64)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+
+		s.poll(ctx, out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.poll(ctx, out)
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *PollingChangeSource) poll(ctx context.Context, out chan<- Event) {
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, s.backend+"/v1/contexts?limit=50", nil)
+	if err != nil {
+		s.logger.Error("poll request build failed", "err", err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.logger.Error("poll request failed", "err", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		s.logger.Error("poll backend returned non-200", "status", resp.StatusCode)
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.logger.Error("poll read body failed", "err", err)
+		return
+	}
+	var data contextsResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		s.logger.Error("poll decode failed", "err", err)
+		return
+	}
+
+	newContexts := make(map[string]contextState)
+	for _, c := range data.Contexts {
+		newContexts[c.ContextID] = contextState{HeadTurnID: c.HeadTurnID, HeadDepth: c.HeadDepth}
+		oldState, exists := s.lastContexts[c.ContextID]
+		switch {
+		case !exists:
+			out <- Event{Type: "context_created", ContextID: c.ContextID, Data: map[string]interface{}{
+				"context_id": c.ContextID,
+				"created_at": c.CreatedAtUnixMs,
+			}}
+		case oldState.HeadTurnID != c.HeadTurnID:
+			out <- Event{Type: "turn_appended", ContextID: c.ContextID, Data: map[string]interface{}{
+				"context_id":     c.ContextID,
+				"turn_id":        c.HeadTurnID,
+				"parent_turn_id": oldState.HeadTurnID,
+				"depth":          c.HeadDepth,
+			}}
+		}
+	}
+	s.lastContexts = newContexts
+}
+
+// PostgresChangeSource subscribes to the contexts/turns triggers via LISTEN/NOTIFY.
+// listener abstracts over *pq.Listener (or any driver exposing the same shape) so this
+// package doesn't force a specific postgres driver on callers who don't need it.
+type PostgresChangeSource struct {
+	listener PostgresListener
+	channels []string
+	logger   *slog.Logger
+}
+
+// PostgresListener is the subset of github.com/lib/pq.Listener this source needs.
+// A *pq.Listener needs a thin adapter to satisfy it, since its notifications arrive
+// as *pq.Notification rather than *PostgresNotification.
+type PostgresListener interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *PostgresNotification
+	Close() error
+}
+
+// PostgresNotification mirrors pq.Notification's fields that matter to us.
+type PostgresNotification struct {
+	Channel string
+	Extra   string
+}
+
+// NewPostgresChangeSource listens on the context_created and turn_appended channels
+// emitted by triggers on the contexts/turns tables.
+func NewPostgresChangeSource(listener PostgresListener, logger *slog.Logger) *PostgresChangeSource {
+	return &PostgresChangeSource{
+		listener: listener,
+		channels: []string{"context_created", "turn_appended"},
+		logger:   logger,
+	}
+}
+
+func (s *PostgresChangeSource) Start(ctx context.Context) (<-chan Event, error) {
+	for _, ch := range s.channels {
+		if err := s.listener.Listen(ch); err != nil {
+			return nil, fmt.Errorf("listen %s: %w", ch, err)
+		}
+	}
+	out := make(chan Event, 64)
+	go func() {
+		defer close(out)
+		defer func() { _ = s.listener.Close() }()
+		notifications := s.listener.NotificationChannel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n, ok := <-notifications:
+				if !ok {
+					return
+				}
+				if n == nil {
+					continue // pq sends a nil notification after a connection re-established
+				}
+				var data map[string]interface{}
+				if err := json.Unmarshal([]byte(n.Extra), &data); err != nil {
+					s.logger.Error("notify payload decode failed", "channel", n.Channel, "err", err)
+					continue
+				}
+				contextID, _ := data["context_id"].(string)
+				out <- Event{Type: n.Channel, ContextID: contextID, Data: data}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// PubSubClient abstracts the handful of NATS/Redis operations a pub-sub ChangeSource
+// needs, so multi-replica deployments can pick whichever broker they already run.
+type PubSubClient interface {
+	Subscribe(ctx context.Context, subject string) (<-chan []byte, error)
+}
+
+// PubSubChangeSource subscribes to the same two logical channels over a generic
+// PubSubClient (NATS or Redis), for deployments running more than one gateway replica
+// where in-process LISTEN/NOTIFY fan-out alone wouldn't reach every replica's clients.
+type PubSubChangeSource struct {
+	client   PubSubClient
+	subjects []string
+	logger   *slog.Logger
+}
+
+// NewPubSubChangeSource subscribes to subjects (typically "cxdb.context_created" and
+// "cxdb.turn_appended") on client.
+func NewPubSubChangeSource(client PubSubClient, subjects []string, logger *slog.Logger) *PubSubChangeSource {
+	return &PubSubChangeSource{client: client, subjects: subjects, logger: logger}
+}
+
+func (s *PubSubChangeSource) Start(ctx context.Context) (<-chan Event, error) {
+	out := make(chan Event, 64)
+	finished := make(chan struct{}, len(s.subjects))
+	for _, subject := range s.subjects {
+		msgs, err := s.client.Subscribe(ctx, subject)
+		if err != nil {
+			return nil, fmt.Errorf("subscribe %s: %w", subject, err)
+		}
+		go func(subject string, msgs <-chan []byte) {
+			s.forward(ctx, subject, msgs, out)
+			finished <- struct{}{}
+		}(subject, msgs)
+	}
+	// Close out once every subject's forwarder has returned.
+	go func() {
+		for range s.subjects {
+			<-finished
+		}
+		close(out)
+	}()
+	return out, nil
+}
+
+func (s *PubSubChangeSource) forward(ctx context.Context, subject string, msgs <-chan []byte, out chan<- Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var data map[string]interface{}
+			if err := json.Unmarshal(msg, &data); err != nil {
+				s.logger.Error("pubsub payload decode failed", "subject", subject, "err", err)
+				continue
+			}
+			contextID, _ := data["context_id"].(string)
+			out <- Event{Type: eventTypeFromSubject(subject), ContextID: contextID, Data: data}
+		}
+	}
+}
+
+func eventTypeFromSubject(subject string) string {
+	// Subjects are namespaced like "cxdb.context_created"; the event type is the part
+	// after the last dot.
+	for i := len(subject) - 1; i >= 0; i-- {
+		if subject[i] == '.' {
+			return subject[i+1:]
+		}
+	}
+	return subject
+}
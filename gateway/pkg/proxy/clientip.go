@@ -0,0 +1,174 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxySet is the parsed form of config.Config.TrustedProxies: only a peer
+// whose RemoteAddr falls inside one of these CIDRs is allowed to set
+// Forwarded/X-Forwarded-For/X-Real-IP, so a direct client can't spoof its IP.
+type trustedProxySet struct {
+	nets []*net.IPNet
+}
+
+// newTrustedProxySet parses cidrs (e.g. "10.0.0.0/8", "192.168.1.1"). A bare IP
+// without a "/prefix" is treated as a /32 (or /128 for IPv6).
+func newTrustedProxySet(cidrs []string) (*trustedProxySet, error) {
+	t := &trustedProxySet{}
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("parse trusted proxy CIDR %q: %w", c, err)
+		}
+		t.nets = append(t.nets, ipNet)
+	}
+	return t, nil
+}
+
+// contains reports whether ip falls inside any configured CIDR. A nil set (no
+// TrustedProxies configured) trusts nothing.
+func (t *trustedProxySet) contains(ip net.IP) bool {
+	if t == nil || ip == nil {
+		return false
+	}
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP resolves the real client IP for r. If RemoteAddr isn't a trusted proxy (or
+// no TrustedProxies are configured), the forwarding headers are ignored entirely and
+// RemoteAddr is returned as-is, since an untrusted peer can set them to anything.
+//
+// For a trusted peer, RFC 7239 Forwarded is preferred over X-Forwarded-For, which is
+// preferred over X-Real-IP. Forwarded/X-Forwarded-For list hops left-to-right from the
+// original client to the nearest proxy, so the chain is walked from the right,
+// skipping entries that are themselves trusted proxies, and stopping at (returning)
+// the first hop that isn't - the closest thing to a real client this chain of trusted
+// proxies vouches for. If every hop is itself a trusted proxy, the left-most entry
+// (closest to the original client) is returned as a best effort.
+func clientIP(r *http.Request, trusted *trustedProxySet) string {
+	remoteIP := remoteAddrIP(r.RemoteAddr)
+	if !trusted.contains(remoteIP) {
+		return hostOrRaw(remoteIP, r.RemoteAddr)
+	}
+
+	if hops := parseForwardedFor(r.Header.Get("Forwarded")); len(hops) > 0 {
+		return firstUntrusted(hops, trusted)
+	}
+	if hops := splitHeaderList(r.Header.Get("X-Forwarded-For")); len(hops) > 0 {
+		return firstUntrusted(hops, trusted)
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	return hostOrRaw(remoteIP, r.RemoteAddr)
+}
+
+// firstUntrusted walks hops (left = original client, right = nearest proxy) from the
+// right, returning the first one that isn't itself a trusted proxy. If every hop is
+// trusted, it falls back to the left-most (oldest) hop.
+func firstUntrusted(hops []string, trusted *trustedProxySet) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip != nil && !trusted.contains(ip) {
+			return hops[i]
+		}
+	}
+	return hops[0]
+}
+
+// splitHeaderList splits a comma-separated header value (X-Forwarded-For) into
+// trimmed, non-empty fields, left-to-right as they appear in the header.
+func splitHeaderList(header string) []string {
+	if header == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(header, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseForwardedFor extracts the "for=" IP of each element of an RFC 7239 Forwarded
+// header, in the order they appear (left = original client, right = nearest proxy).
+// Obfuscated identifiers ("for=unknown", "for=_hidden") are skipped since they can't
+// be compared against trusted CIDRs.
+func parseForwardedFor(header string) []string {
+	if header == "" {
+		return nil
+	}
+	var out []string
+	for _, elem := range strings.Split(header, ",") {
+		for _, pair := range strings.Split(elem, ";") {
+			pair = strings.TrimSpace(pair)
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(k), "for") {
+				continue
+			}
+			if ip := parseForwardedNode(strings.TrimSpace(v)); ip != "" {
+				out = append(out, ip)
+			}
+		}
+	}
+	return out
+}
+
+// parseForwardedNode strips the quoting, brackets, and port RFC 7239 allows around a
+// "for=" node identifier, e.g. `"[2001:db8::1]:443"` -> "2001:db8::1", returning "" for
+// obfuscated identifiers it can't turn into an IP.
+func parseForwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+	if strings.HasPrefix(node, "[") {
+		// Bracketed IPv6, optionally with a port: [::1]:8080
+		if end := strings.Index(node, "]"); end != -1 {
+			return node[1:end]
+		}
+	}
+	// IPv4 with an optional port, or a bare IPv4/IPv6 address.
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	if net.ParseIP(node) != nil {
+		return node
+	}
+	return ""
+}
+
+func remoteAddrIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func hostOrRaw(ip net.IP, remoteAddr string) string {
+	if ip != nil {
+		return ip.String()
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
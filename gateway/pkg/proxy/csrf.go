@@ -0,0 +1,102 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+const (
+	csrfCookieName = "cxdb_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+type csrfContextKey struct{}
+
+// CSRFTokenFromContext returns the CSRF token issued to this request's session, or ""
+// if the request isn't cookie-authenticated. /api/v1/me emits this so the React
+// frontend can attach it as X-CSRF-Token on state-changing requests.
+func CSRFTokenFromContext(ctx context.Context) string {
+	tok, _ := ctx.Value(csrfContextKey{}).(string)
+	return tok
+}
+
+// csrfMiddleware implements double-submit CSRF protection for cookie-authenticated
+// writes: it issues a random token in a non-HttpOnly cxdb_csrf cookie on authenticated
+// responses, and requires a matching X-CSRF-Token header (constant-time compared) on
+// every non-GET/HEAD/OPTIONS /v1/* or /api/* request. Bearer-token requests (K8s OIDC,
+// AWS IAM) don't carry the session cookie in the first place, so they're exempt.
+func (s *Server) csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isBearerRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := csrfCookieValue(r)
+		if token == "" && auth.UserFromContext(r.Context()) != nil {
+			var err error
+			token, err = newCSRFToken()
+			if err != nil {
+				http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    token,
+				Domain:   s.cfg.CookieDomain,
+				Path:     "/",
+				HttpOnly: false,
+				Secure:   s.hstsEnabled,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if requiresCSRFCheck(r) {
+			header := r.Header.Get(csrfHeaderName)
+			if token == "" || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+				http.Error(w, `{"error":"csrf token missing or invalid"}`, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token)))
+	})
+}
+
+// requiresCSRFCheck reports whether r is a state-changing request to a route the
+// double-submit check protects.
+func requiresCSRFCheck(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, "/v1/") || strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func isBearerRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func csrfCookieValue(r *http.Request) string {
+	c, err := r.Cookie(csrfCookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
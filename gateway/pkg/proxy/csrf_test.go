@@ -0,0 +1,146 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/strongdm/cxdb/gateway/internal/config"
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+func TestRequiresCSRFCheck(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{http.MethodGet, "/v1/contexts", false},
+		{http.MethodHead, "/v1/contexts", false},
+		{http.MethodOptions, "/v1/contexts", false},
+		{http.MethodPost, "/v1/contexts", true},
+		{http.MethodPut, "/api/v1/me", true},
+		{http.MethodPost, "/healthz", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if got := requiresCSRFCheck(r); got != tc.want {
+			t.Errorf("requiresCSRFCheck(%s %s) = %v, want %v", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestIsBearerRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/contexts", nil)
+	if isBearerRequest(r) {
+		t.Fatal("request with no Authorization header should not be treated as a bearer request")
+	}
+	r.Header.Set("Authorization", "Bearer abc123")
+	if !isBearerRequest(r) {
+		t.Fatal("request with an Authorization: Bearer header should be treated as a bearer request")
+	}
+}
+
+func newTestCSRFServer() *Server {
+	return &Server{cfg: config.Config{CookieDomain: ""}}
+}
+
+func TestCSRFMiddlewareIssuesTokenForAuthenticatedSession(t *testing.T) {
+	s := newTestCSRFServer()
+	handler := s.csrfMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/contexts", nil)
+	r = r.WithContext(auth.WithUser(r.Context(), &auth.Session{ID: "s1"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	found := false
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName && c.Value != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("csrfMiddleware should set a non-empty CSRF cookie for an authenticated session")
+	}
+}
+
+func TestCSRFMiddlewareRejectsWriteWithoutToken(t *testing.T) {
+	s := newTestCSRFServer()
+	called := false
+	handler := s.csrfMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/contexts", nil)
+	r = r.WithContext(auth.WithUser(r.Context(), &auth.Session{ID: "s1"}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if called {
+		t.Fatal("a state-changing request with no CSRF token should be rejected before reaching next")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestCSRFMiddlewareAllowsWriteWithMatchingToken(t *testing.T) {
+	s := newTestCSRFServer()
+	called := false
+	handler := s.csrfMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// First, a GET establishes the cookie.
+	get := httptest.NewRequest(http.MethodGet, "/v1/contexts", nil)
+	get = get.WithContext(auth.WithUser(get.Context(), &auth.Session{ID: "s1"}))
+	getW := httptest.NewRecorder()
+	handler.ServeHTTP(getW, get)
+
+	var token string
+	for _, c := range getW.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		t.Fatal("expected a CSRF cookie to be issued on the GET")
+	}
+
+	post := httptest.NewRequest(http.MethodPost, "/v1/contexts", nil)
+	post = post.WithContext(auth.WithUser(post.Context(), &auth.Session{ID: "s1"}))
+	post.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	post.Header.Set(csrfHeaderName, token)
+	postW := httptest.NewRecorder()
+	handler.ServeHTTP(postW, post)
+
+	if !called {
+		t.Fatal("a write with a matching X-CSRF-Token header should reach next")
+	}
+	if postW.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", postW.Code, http.StatusOK)
+	}
+}
+
+func TestCSRFMiddlewareExemptsBearerRequests(t *testing.T) {
+	s := newTestCSRFServer()
+	called := false
+	handler := s.csrfMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/contexts", nil)
+	r.Header.Set("Authorization", "Bearer some-token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if !called {
+		t.Fatal("a bearer-authenticated write should bypass the CSRF check entirely")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyProtocolListener wraps a net.Listener, parsing an HAProxy PROXY protocol v1 or
+// v2 header off the front of each accepted connection and exposing the original
+// client address via the returned Conn's RemoteAddr(). This lets an L4 load balancer's
+// TCP source address (usually the LB itself) get replaced by the real client IP that
+// rate limiting and audit logs rely on, without relying on HTTP
+// headers the L4 balancer has no way to rewrite.
+type proxyProtocolListener struct {
+	net.Listener
+	headerTimeout time.Duration
+}
+
+// newProxyProtocolListener wraps inner so every connection it Accepts is expected to
+// start with a PROXY protocol v1 or v2 header.
+func newProxyProtocolListener(inner net.Listener) *proxyProtocolListener {
+	return &proxyProtocolListener{Listener: inner, headerTimeout: 5 * time.Second}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(l.headerTimeout))
+	br := bufio.NewReader(conn)
+	remote, err := readProxyProtocolHeader(br)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("read PROXY protocol header: %w", err)
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	return &proxyProtocolConn{Conn: conn, reader: br, remoteAddr: remote}, nil
+}
+
+// proxyProtocolConn overrides RemoteAddr with the address the PROXY protocol header
+// reported, reading through the bufio.Reader that buffered bytes past the header
+// while readProxyProtocolHeader parsed it.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) { return c.reader.Read(b) }
+func (c *proxyProtocolConn) RemoteAddr() net.Addr       { return c.remoteAddr }
+
+var errUnsupportedProxyProtocol = errors.New("unsupported or malformed PROXY protocol header")
+
+// proxyProtoV2Sig is the fixed 12-byte signature every v2 header starts with.
+const proxyProtoV2Sig = "\r\n\r\n\x00\r\nQUIT\n"
+
+// readProxyProtocolHeader detects and parses a v1 (text) or v2 (binary) PROXY
+// protocol header from br, returning the original client address it describes.
+func readProxyProtocolHeader(br *bufio.Reader) (net.Addr, error) {
+	sig, err := br.Peek(len(proxyProtoV2Sig))
+	if err == nil && string(sig) == proxyProtoV2Sig {
+		return readProxyProtocolV2(br)
+	}
+	return readProxyProtocolV1(br)
+}
+
+// readProxyProtocolV1 parses a line like "PROXY TCP4 <src ip> <dst ip> <src port> <dst port>\r\n".
+func readProxyProtocolV1(br *bufio.Reader) (net.Addr, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(strings.TrimRight(line, "\r\n"))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errUnsupportedProxyProtocol
+	}
+	switch fields[1] {
+	case "TCP4", "TCP6":
+		if len(fields) < 6 {
+			return nil, errUnsupportedProxyProtocol
+		}
+		ip := net.ParseIP(fields[2])
+		port, err := strconv.Atoi(fields[4])
+		if ip == nil || err != nil {
+			return nil, errUnsupportedProxyProtocol
+		}
+		return &net.TCPAddr{IP: ip, Port: port}, nil
+	case "UNKNOWN":
+		return &net.TCPAddr{}, nil
+	default:
+		return nil, errUnsupportedProxyProtocol
+	}
+}
+
+// readProxyProtocolV2 parses the binary v2 header: 12-byte signature, 1-byte
+// ver/command, 1-byte family/protocol, 2-byte big-endian address length, then the
+// address block itself.
+func readProxyProtocolV2(br *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	verCmd := header[12]
+	if verCmd>>4 != 2 {
+		return nil, errUnsupportedProxyProtocol
+	}
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+	addr := make([]byte, length)
+	if _, err := io.ReadFull(br, addr); err != nil {
+		return nil, err
+	}
+	// Command LOCAL (low nibble 0): a health check from the proxy itself, with no
+	// real client address to parse.
+	if verCmd&0x0F == 0 {
+		return &net.TCPAddr{}, nil
+	}
+	switch family {
+	case 0x1: // AF_INET
+		if len(addr) < 12 {
+			return nil, errUnsupportedProxyProtocol
+		}
+		return &net.TCPAddr{IP: net.IP(addr[0:4]), Port: int(binary.BigEndian.Uint16(addr[8:10]))}, nil
+	case 0x2: // AF_INET6
+		if len(addr) < 36 {
+			return nil, errUnsupportedProxyProtocol
+		}
+		return &net.TCPAddr{IP: net.IP(addr[0:16]), Port: int(binary.BigEndian.Uint16(addr[32:34]))}, nil
+	default:
+		return nil, errUnsupportedProxyProtocol
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+const (
+	rateLimiterShardCount      = 32
+	rateLimiterIdleTTL         = 10 * time.Minute
+	rateLimiterJanitorInterval = time.Minute
+)
+
+// RoutePolicy configures the token-bucket rate applied to requests whose path starts
+// with Prefix. PerUser additionally rate-limits by the authenticated session's ID on
+// top of the per-IP bucket, so a single logged-in user behind a NAT can't exhaust the
+// shared IP bucket for everyone else on that IP.
+type RoutePolicy struct {
+	Prefix  string
+	RPS     rate.Limit
+	Burst   int
+	PerUser bool
+}
+
+// defaultRoutePolicies are the gateway's built-in rate limit policies, most specific
+// prefix wins. /v1/events has no policy of its own, so each SSE connect counts against
+// the /v1/ bucket like any other request; since connections are long-lived, how many
+// are held open at once is capped separately (see sseConcurrencyLimiter).
+var defaultRoutePolicies = []RoutePolicy{
+	{Prefix: "/auth/", RPS: 5, Burst: 10},
+	{Prefix: "/login", RPS: 5, Burst: 10},
+	{Prefix: "/v1/", RPS: 20, Burst: 50, PerUser: true},
+}
+
+// matchRoutePolicy returns the longest-prefix policy matching path, or false if no
+// policy applies (the path isn't rate limited at all).
+func matchRoutePolicy(policies []RoutePolicy, path string) (RoutePolicy, bool) {
+	path = strings.ToLower(path)
+	var best RoutePolicy
+	matched := false
+	for _, p := range policies {
+		if strings.HasPrefix(path, p.Prefix) && len(p.Prefix) >= len(best.Prefix) {
+			best = p
+			matched = true
+		}
+	}
+	return best, matched
+}
+
+// bucketEntry is one token bucket plus the last time it was touched, so the janitor
+// can tell idle buckets apart from active ones.
+type bucketEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// limiterShard is one mutex-partitioned slice of a shardedLimiter's keyspace.
+type limiterShard struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketEntry
+}
+
+// shardedLimiter is a token-bucket limiter keyed by an arbitrary string (an IP or a
+// session ID), partitioned across rateLimiterShardCount mutex-guarded shards so
+// requests for unrelated keys don't contend on one lock. Idle buckets are evicted by
+// the owning policyRateLimiter's janitor so a public-facing gateway doesn't grow this
+// map without bound.
+type shardedLimiter struct {
+	shards [rateLimiterShardCount]*limiterShard
+	rps    rate.Limit
+	burst  int
+}
+
+func newShardedLimiter(rps rate.Limit, burst int) *shardedLimiter {
+	l := &shardedLimiter{rps: rps, burst: burst}
+	for i := range l.shards {
+		l.shards[i] = &limiterShard{buckets: make(map[string]*bucketEntry)}
+	}
+	return l
+}
+
+func (l *shardedLimiter) shardFor(key string) *limiterShard {
+	sum := sha256.Sum256([]byte(key))
+	idx := binary.BigEndian.Uint32(sum[:4]) % rateLimiterShardCount
+	return l.shards[idx]
+}
+
+// allow reports whether key's bucket has a token available, creating the bucket on
+// first use.
+func (l *shardedLimiter) allow(key string) bool {
+	sh := l.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	entry, ok := sh.buckets[key]
+	if !ok {
+		entry = &bucketEntry{limiter: rate.NewLimiter(l.rps, l.burst)}
+		sh.buckets[key] = entry
+	}
+	entry.lastUsed = time.Now()
+	return entry.limiter.Allow()
+}
+
+// evictIdle removes every bucket last used before cutoff.
+func (l *shardedLimiter) evictIdle(cutoff time.Time) {
+	for _, sh := range l.shards {
+		sh.mu.Lock()
+		for key, entry := range sh.buckets {
+			if entry.lastUsed.Before(cutoff) {
+				delete(sh.buckets, key)
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// policyRateLimiter enforces a set of RoutePolicy rules, keeping one sharded per-IP
+// limiter and (for PerUser policies) one sharded per-user limiter per policy.
+type policyRateLimiter struct {
+	policies []RoutePolicy
+	byIP     map[string]*shardedLimiter
+	byUser   map[string]*shardedLimiter
+	trusted  *trustedProxySet
+}
+
+// newPolicyRateLimiter builds the limiters for policies, resolving each request's
+// per-IP bucket key via trusted (see clientip.go). Call Start to launch the idle-bucket
+// janitor.
+func newPolicyRateLimiter(policies []RoutePolicy, trusted *trustedProxySet) *policyRateLimiter {
+	l := &policyRateLimiter{
+		policies: policies,
+		byIP:     make(map[string]*shardedLimiter),
+		byUser:   make(map[string]*shardedLimiter),
+		trusted:  trusted,
+	}
+	for _, p := range policies {
+		l.byIP[p.Prefix] = newShardedLimiter(p.RPS, p.Burst)
+		if p.PerUser {
+			l.byUser[p.Prefix] = newShardedLimiter(p.RPS, p.Burst)
+		}
+	}
+	return l
+}
+
+// Start launches the background janitor that evicts buckets idle longer than
+// rateLimiterIdleTTL. Start returns immediately; the janitor stops once ctx is done.
+func (l *policyRateLimiter) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rateLimiterJanitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cutoff := time.Now().Add(-rateLimiterIdleTTL)
+				for _, sl := range l.byIP {
+					sl.evictIdle(cutoff)
+				}
+				for _, sl := range l.byUser {
+					sl.evictIdle(cutoff)
+				}
+			}
+		}
+	}()
+}
+
+// Allow reports whether r is within its route policy's rate limit. Paths without a
+// matching policy are always allowed. PerUser policies are also checked against the
+// authenticated session's per-user bucket, on top of the per-IP bucket, when the
+// request carries a resolved session.
+func (l *policyRateLimiter) Allow(r *http.Request) bool {
+	policy, ok := matchRoutePolicy(l.policies, r.URL.Path)
+	if !ok {
+		return true
+	}
+	if !l.byIP[policy.Prefix].allow(clientIP(r, l.trusted)) {
+		return false
+	}
+	if policy.PerUser {
+		if sess := auth.UserFromContext(r.Context()); sess != nil {
+			return l.byUser[policy.Prefix].allow(sess.ID)
+		}
+	}
+	return true
+}
+
+// sseConcurrencyLimiter caps the number of simultaneous /v1/events connections per
+// key (an authenticated session ID, or an IP for anonymous/dev-bypass connections).
+// SSE connections are long-lived, so a token bucket doesn't fit the "how many are open
+// right now" question the way it fits a request rate.
+type sseConcurrencyLimiter struct {
+	max int
+
+	mu    sync.Mutex
+	inUse map[string]int
+}
+
+func newSSEConcurrencyLimiter(max int) *sseConcurrencyLimiter {
+	return &sseConcurrencyLimiter{max: max, inUse: make(map[string]int)}
+}
+
+func (l *sseConcurrencyLimiter) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inUse[key] >= l.max {
+		return false
+	}
+	l.inUse[key]++
+	return true
+}
+
+func (l *sseConcurrencyLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse[key]--
+	if l.inUse[key] <= 0 {
+		delete(l.inUse, key)
+	}
+}
+
+func sseConcurrencyKey(r *http.Request, trusted *trustedProxySet) string {
+	if sess := auth.UserFromContext(r.Context()); sess != nil {
+		return "user:" + sess.ID
+	}
+	return "ip:" + clientIP(r, trusted)
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+func TestMatchRoutePolicyLongestPrefixWins(t *testing.T) {
+	policies := []RoutePolicy{
+		{Prefix: "/v1/", RPS: 20, Burst: 50},
+		{Prefix: "/v1/admin/", RPS: 1, Burst: 1},
+	}
+
+	got, ok := matchRoutePolicy(policies, "/v1/admin/users")
+	if !ok || got.Prefix != "/v1/admin/" {
+		t.Fatalf("matchRoutePolicy = %+v, %v, want the more specific /v1/admin/ policy", got, ok)
+	}
+
+	got, ok = matchRoutePolicy(policies, "/v1/contexts")
+	if !ok || got.Prefix != "/v1/" {
+		t.Fatalf("matchRoutePolicy = %+v, %v, want the /v1/ policy", got, ok)
+	}
+}
+
+func TestMatchRoutePolicyNoMatch(t *testing.T) {
+	policies := []RoutePolicy{{Prefix: "/v1/", RPS: 20, Burst: 50}}
+	if _, ok := matchRoutePolicy(policies, "/healthz"); ok {
+		t.Fatal("matchRoutePolicy should report no match for an unrelated path")
+	}
+}
+
+func TestShardedLimiterAllowRespectsBurst(t *testing.T) {
+	l := newShardedLimiter(rate.Limit(1), 2)
+	if !l.allow("k") || !l.allow("k") {
+		t.Fatal("first two requests within burst should be allowed")
+	}
+	if l.allow("k") {
+		t.Fatal("third immediate request should be denied once the burst is exhausted")
+	}
+}
+
+func TestShardedLimiterKeysAreIndependent(t *testing.T) {
+	l := newShardedLimiter(rate.Limit(1), 1)
+	if !l.allow("a") {
+		t.Fatal("first request for key a should be allowed")
+	}
+	if !l.allow("b") {
+		t.Fatal("key b must have its own bucket, independent of key a")
+	}
+}
+
+func TestShardedLimiterEvictIdle(t *testing.T) {
+	l := newShardedLimiter(rate.Limit(1), 1)
+	l.allow("stale")
+	l.evictIdle(time.Now().Add(time.Minute))
+
+	sh := l.shardFor("stale")
+	sh.mu.Lock()
+	_, exists := sh.buckets["stale"]
+	sh.mu.Unlock()
+	if exists {
+		t.Fatal("evictIdle should have removed a bucket last used before the cutoff")
+	}
+}
+
+func TestPolicyRateLimiterPerUserBucketIsSeparateFromPerIP(t *testing.T) {
+	policies := []RoutePolicy{{Prefix: "/v1/", RPS: 1, Burst: 1, PerUser: true}}
+	l := newPolicyRateLimiter(policies, nil)
+
+	sess := &auth.Session{ID: "user-1"}
+	r1 := httptest.NewRequest(http.MethodGet, "/v1/contexts", nil)
+	r1.RemoteAddr = "10.0.0.1:1234"
+	r1 = r1.WithContext(auth.WithUser(r1.Context(), sess))
+
+	if !l.Allow(r1) {
+		t.Fatal("first request should be allowed")
+	}
+	if l.Allow(r1) {
+		t.Fatal("second immediate request from the same user should be denied")
+	}
+
+	// A different IP authenticated as the same user still shares that user's bucket.
+	r2 := httptest.NewRequest(http.MethodGet, "/v1/contexts", nil)
+	r2.RemoteAddr = "10.0.0.2:1234"
+	r2 = r2.WithContext(auth.WithUser(r2.Context(), sess))
+	if l.Allow(r2) {
+		t.Fatal("per-user bucket should deny a second request for the same session regardless of IP")
+	}
+}
+
+func TestSSEConcurrencyLimiterCapsPerKey(t *testing.T) {
+	l := newSSEConcurrencyLimiter(1)
+	if !l.acquire("k") {
+		t.Fatal("first acquire should succeed")
+	}
+	if l.acquire("k") {
+		t.Fatal("second acquire for the same key should be denied once at capacity")
+	}
+	l.release("k")
+	if !l.acquire("k") {
+		t.Fatal("acquire should succeed again after release")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 StrongDM Inc
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+
+	"github.com/strongdm/cxdb/gateway/pkg/auth"
+)
+
+// identityHeadersToStrip are removed from every incoming request before it's
+// forwarded, so a client can't impersonate another user by setting these headers
+// itself - only the gateway, after resolving auth, is allowed to set them.
+var identityHeadersToStrip = []string{
+	"X-Auth-Request-Email",
+	"X-Auth-Request-User",
+	"X-Auth-Request-Groups",
+	"X-Forwarded-User",
+}
+
+// backendJWTTTL is how long a freshly-minted backend JWT is valid for. Requests are
+// proxied synchronously, so it only needs to outlive one backend round trip.
+const backendJWTTTL = 5 * time.Minute
+
+// backendJWTAudience is the aud claim every minted backend JWT carries.
+const backendJWTAudience = "cxdb-backend"
+
+// ReverseProxy forwards /v1/* requests to the cxdb backend. After auth resolves a
+// caller (session cookie, K8s OIDC, or AWS IAM - see auth.RequireAuthForReadsWithOptions),
+// it strips any client-supplied identity headers and sets a curated set
+// (X-Auth-Request-Email/User/Groups) from the resolved auth.Session, mirroring how
+// oauth2_proxy/gatekeeper forward identity downstream so the backend doesn't need to
+// re-implement session or token parsing itself.
+type ReverseProxy struct {
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+	logger *slog.Logger
+
+	forwardAccessToken bool
+	mintJWT            bool
+	jwtSigningKey      []byte
+}
+
+// NewReverseProxy builds a ReverseProxy forwarding to backendURL. By default it only
+// sets the X-Auth-Request-* headers; call ForwardAccessToken and/or MintBackendJWT to
+// also attach an Authorization header.
+func NewReverseProxy(backendURL string, logger *slog.Logger) (*ReverseProxy, error) {
+	target, err := url.Parse(backendURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse backend URL: %w", err)
+	}
+
+	p := &ReverseProxy{target: target, logger: logger}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			stripIdentityHeaders(pr.Out)
+			p.injectIdentityHeaders(pr.Out)
+		},
+		ErrorHandler: p.handleProxyError,
+	}
+	return p, nil
+}
+
+// ForwardAccessToken makes every proxied request with a resolved Session carry that
+// session's (transparently refreshed) OAuth access token as "Authorization: Bearer
+// <token>", instead of whatever Authorization header the client itself sent.
+func (p *ReverseProxy) ForwardAccessToken() {
+	p.forwardAccessToken = true
+}
+
+// MintBackendJWT enables signing a short-lived HS256 JWT (sub, email, groups, aud,
+// exp) from key and attaching it as "Authorization: Bearer <token>" on every proxied
+// request with a resolved Session, when ForwardAccessToken hasn't already supplied
+// one. HS256 (not RS256) is used because key is the gateway's existing SessionSecret -
+// a shared HMAC secret, not an RSA keypair - so the backend verifies with that same
+// secret rather than fetching a JWKS.
+func (p *ReverseProxy) MintBackendJWT(key string) {
+	p.mintJWT = true
+	p.jwtSigningKey = []byte(key)
+}
+
+// Target returns the backend base URL this proxy forwards to.
+func (p *ReverseProxy) Target() string {
+	return p.target.String()
+}
+
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.proxy.ServeHTTP(w, r)
+}
+
+func (p *ReverseProxy) handleProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	p.logger.Error("backend proxy error", "path", r.URL.Path, "err", err)
+	http.Error(w, `{"error":"backend unavailable"}`, http.StatusBadGateway)
+}
+
+func stripIdentityHeaders(out *http.Request) {
+	for _, h := range identityHeadersToStrip {
+		out.Header.Del(h)
+	}
+}
+
+// injectIdentityHeaders sets the curated identity headers (and, if configured, the
+// Authorization header) from the auth.Session that RequireAuthForReadsWithOptions
+// attached to out's original request context. Requests with no resolved session (dev
+// bypass, or a write the gateway forwards without requiring one) are left with no
+// identity headers at all, and their own Authorization header - if any - passes
+// through untouched.
+func (p *ReverseProxy) injectIdentityHeaders(out *http.Request) {
+	sess := auth.UserFromContext(out.Context())
+	if sess == nil {
+		return
+	}
+
+	out.Header.Set("X-Auth-Request-Email", sess.Email)
+	out.Header.Set("X-Auth-Request-User", sess.Name)
+	out.Header.Set("X-Auth-Request-Groups", strings.Join(sess.Groups, ","))
+
+	if p.forwardAccessToken {
+		if token, err := sess.AccessToken(out.Context()); err == nil && token != "" {
+			out.Header.Set("Authorization", "Bearer "+token)
+			return
+		}
+	}
+	if p.mintJWT {
+		token, err := p.signBackendJWT(sess)
+		if err != nil {
+			p.logger.Warn("backend jwt signing failed", "session", sess.ID, "err", err)
+			out.Header.Del("Authorization")
+			return
+		}
+		out.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// signBackendJWT mints the HS256 JWT described on MintBackendJWT for sess.
+func (p *ReverseProxy) signBackendJWT(sess *auth.Session) (string, error) {
+	tok, err := jwt.NewBuilder().
+		Subject(sess.ID).
+		Claim("email", sess.Email).
+		Claim("groups", sess.Groups).
+		Audience([]string{backendJWTAudience}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(backendJWTTTL)).
+		Build()
+	if err != nil {
+		return "", fmt.Errorf("build backend jwt: %w", err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, p.jwtSigningKey))
+	if err != nil {
+		return "", fmt.Errorf("sign backend jwt: %w", err)
+	}
+	return string(signed), nil
+}
@@ -11,40 +11,48 @@ import (
 	"net"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/strongdm/cxdb/gateway/internal/config"
 	"github.com/strongdm/cxdb/gateway/pkg/auth"
-	"golang.org/x/time/rate"
 )
 
 // Server wires together config, auth, and the reverse proxy.
 type Server struct {
-	cfg      config.Config
-	mux      *http.ServeMux
-	sessions *auth.SessionStore
-	google   *auth.GoogleAuth
-	proxy    *ReverseProxy
-	sse      *SSEBroker
-	logger   *slog.Logger
-	staticFS fs.FS
-
-	cspHeader   string
-	hstsEnabled bool
-	limiters    *ipRateLimiter
+	cfg       config.Config
+	mux       *http.ServeMux
+	sessions  *auth.SessionStore
+	providers *auth.ProviderRegistry
+	proxy     *ReverseProxy
+	sse       *SSEBroker
+	logger    *slog.Logger
+	staticFS  fs.FS
+
+	cspHeader      string
+	hstsEnabled    bool
+	limiters       *policyRateLimiter
+	sseLimiter     *sseConcurrencyLimiter
+	trustedProxies *trustedProxySet
+	auditLogger    *AuditLogger
 
 	// Service-to-service auth verifiers (optional)
 	tokenVerifiers []auth.BearerTokenVerifier
 	awsExchanger   *auth.AWSTokenExchanger
 }
 
-// New constructs the HTTP server and registers all routes.
-func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth, proxy *ReverseProxy, staticFS fs.FS, logger *slog.Logger) (*Server, error) {
+// New constructs the HTTP server and registers all routes. changeSource drives
+// SSEBroker's live events; pass nil to fall back to PollingChangeSource (the
+// zero-config default cfg.ChangeSourceBackend="poll" selects) - callers that build a
+// PostgresChangeSource or PubSubChangeSource based on cfg.ChangeSourceBackend pass it
+// here instead.
+func New(cfg config.Config, sessions *auth.SessionStore, providers *auth.ProviderRegistry, proxy *ReverseProxy, changeSource ChangeSource, staticFS fs.FS, logger *slog.Logger) (*Server, error) {
 	mux := http.NewServeMux()
 
-	// Create SSE broker for live events
-	sseBroker := NewSSEBroker(proxy.Target(), logger)
+	// Create SSE broker for live events.
+	if changeSource == nil {
+		changeSource = NewPollingChangeSource(proxy.Target(), 2*time.Second, logger)
+	}
+	sseBroker := NewSSEBroker(changeSource, logger)
 
 	// Build CSP header with dynamic renderer origins
 	scriptSrc := "'self' 'unsafe-inline'"
@@ -52,15 +60,27 @@ func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth
 		scriptSrc += " " + origin
 	}
 
+	// form-action only needs to allow whichever identity providers are actually
+	// registered, so a Keycloak-only deployment doesn't carry Google's hosts around.
+	formAction := "form-action 'self'"
+	for _, host := range providers.FormActionHosts() {
+		formAction += " " + host
+	}
+
+	trustedProxies, err := newTrustedProxySet(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxies: %w", err)
+	}
+
 	s := &Server{
-		cfg:      cfg,
-		mux:      mux,
-		sessions: sessions,
-		google:   google,
-		proxy:    proxy,
-		sse:      sseBroker,
-		logger:   logger,
-		staticFS: staticFS,
+		cfg:       cfg,
+		mux:       mux,
+		sessions:  sessions,
+		providers: providers,
+		proxy:     proxy,
+		sse:       sseBroker,
+		logger:    logger,
+		staticFS:  staticFS,
 		cspHeader: strings.Join([]string{
 			"default-src 'self'",
 			"img-src 'self' data: https://lh3.googleusercontent.com",
@@ -68,11 +88,13 @@ func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth
 			"style-src 'self' 'unsafe-inline'",
 			"connect-src 'self'",
 			"frame-ancestors 'none'",
-			"form-action 'self' https://accounts.google.com https://*.google.com",
+			formAction,
 			"base-uri 'self'",
 		}, "; "),
-		hstsEnabled: strings.HasPrefix(strings.ToLower(cfg.PublicBaseURL), "https://"),
-		limiters:    newIPRateLimiter(rate.Limit(5), 10),
+		hstsEnabled:    strings.HasPrefix(strings.ToLower(cfg.PublicBaseURL), "https://"),
+		limiters:       newPolicyRateLimiter(defaultRoutePolicies, trustedProxies),
+		sseLimiter:     newSSEConcurrencyLimiter(1),
+		trustedProxies: trustedProxies,
 	}
 
 	// Initialize K8s OIDC verifier if enabled
@@ -110,14 +132,25 @@ func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth
 		logger.Info("aws_iam_enabled", "allowed_roles", len(cfg.AWSIAMAllowedRoles), "token_ttl", cfg.AWSIAMTokenTTL)
 	}
 
+	// Audit logging for non-GET /v1/* writes (compliance requirement - loggingMiddleware's
+	// access log never captures bodies). Disabled by default since it's a stricter
+	// guarantee than every deployment needs.
+	if cfg.AuditEnabled {
+		sinks, err := buildAuditSinks(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init audit sinks: %w", err)
+		}
+		s.auditLogger = NewAuditLogger(sinks, cfg.AuditMaxBodyBytes, cfg.AuditRedactPaths, trustedProxies, logger)
+		logger.Info("audit_enabled", "sinks", cfg.AuditSinks)
+	}
+
 	// Health check endpoints (public)
 	mux.HandleFunc("/healthz", s.healthz)
 	mux.HandleFunc("/readyz", s.readyz)
 
-	// OAuth endpoints (public)
-	mux.HandleFunc("/auth/google/login", google.LoginHandler)
-	mux.HandleFunc("/auth/google/callback", google.CallbackHandler)
-	mux.HandleFunc("/auth/google/logout", google.LogoutHandler)
+	// OAuth endpoints (public): /auth/<id>/{login,callback} per registered provider,
+	// plus a single /auth/logout shared across all of them.
+	providers.Mount(mux)
 
 	// AWS IAM token exchange endpoint (public - uses AWS creds for auth)
 	if s.awsExchanger != nil {
@@ -127,11 +160,19 @@ func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth
 	// API info endpoint
 	mux.HandleFunc("/api/v1/me", s.me)
 
-	// SSE endpoint for live events (must be before /v1/ catch-all)
-	mux.Handle("/v1/events", sseBroker)
-
-	// Reverse proxy for all /v1/* endpoints
-	mux.Handle("/v1/", proxy)
+	// SSE endpoint for live events (must be before /v1/ catch-all). Besides the /v1/
+	// token bucket every connect passes through, open streams are capped at one per user
+	// (or per IP, for anonymous/dev-bypass connections), since a stream stays open
+	// rather than completing.
+	mux.Handle("/v1/events", s.sseConcurrencyMiddleware(sseBroker))
+
+	// Reverse proxy for all /v1/* endpoints. Audited when enabled: AuditLogger itself
+	// ignores GET/HEAD, so reads still pass straight through to proxy.
+	v1Handler := http.Handler(proxy)
+	if s.auditLogger != nil {
+		v1Handler = s.auditLogger.Middleware(v1Handler)
+	}
+	mux.Handle("/v1/", v1Handler)
 
 	// Serve embedded React frontend for all other routes
 	mux.Handle("/", s.staticHandler())
@@ -139,18 +180,50 @@ func New(cfg config.Config, sessions *auth.SessionStore, google *auth.GoogleAuth
 	return s, nil
 }
 
+// buildAuditSinks constructs one AuditSink per name in cfg.AuditSinks.
+func buildAuditSinks(cfg config.Config, logger *slog.Logger) ([]AuditSink, error) {
+	sinks := make([]AuditSink, 0, len(cfg.AuditSinks))
+	for _, name := range cfg.AuditSinks {
+		switch name {
+		case "stdout":
+			sinks = append(sinks, NewStdoutAuditSink())
+		case "file":
+			fileSink, err := NewFileAuditSink(cfg.AuditLogFilePath, cfg.AuditLogFileMaxBytes)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, fileSink)
+		case "webhook":
+			sinks = append(sinks, NewWebhookAuditSink(
+				cfg.AuditWebhookURL,
+				cfg.AuditWebhookBatchSize,
+				cfg.AuditWebhookFlushInterval,
+				cfg.AuditWebhookMaxRetries,
+				logger,
+			))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
 // ListenAndServe starts the HTTP server and blocks until it exits.
 func (s *Server) ListenAndServe(ctx context.Context) error {
 	// Start SSE broker polling
 	s.sse.Start(ctx)
 
+	// Start the rate limiter's idle-bucket janitor.
+	s.limiters.Start(ctx)
+
 	addr := fmt.Sprintf(":%s", s.cfg.Port)
+	// rateLimitMiddleware sits inside auth (not outside, like securityHeaders/logging)
+	// so its per-user policies can see the Session auth attaches to the context.
 	handler := auth.RequireAuthForReadsWithOptions(auth.AuthMiddlewareOptions{
-		Store:          s.sessions,
+		Encoder:        s.providers.Encoder(),
 		DevBypass:      s.cfg.DevMode,
 		TokenVerifiers: s.tokenVerifiers,
-	}, s.mux)
-	handler = s.rateLimitMiddleware(handler)
+	}, s.rateLimitMiddleware(s.csrfMiddleware(s.mux)))
 	handler = s.securityHeaders(handler)
 	handler = s.loggingMiddleware(handler)
 
@@ -169,8 +242,34 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			s.logger.Error("server shutdown error", "err", err)
 		}
+		if s.auditLogger != nil {
+			if err := s.auditLogger.Close(); err != nil {
+				s.logger.Error("audit logger close error", "err", err)
+			}
+		}
 	}()
 
+	// ProxyProtocolEnabled accepts connections on a second, dedicated listener that
+	// expects an HAProxy PROXY protocol v1/v2 header first, for deployments behind an
+	// L4 load balancer that can't rewrite HTTP headers to carry the client's real IP.
+	if s.cfg.ProxyProtocolEnabled {
+		proxyAddr := fmt.Sprintf(":%s", s.cfg.ProxyProtocolPort)
+		ln, err := net.Listen("tcp", proxyAddr)
+		if err != nil {
+			return fmt.Errorf("listen on proxy protocol port: %w", err)
+		}
+		go func() {
+			<-ctx.Done()
+			_ = ln.Close()
+		}()
+		go func() {
+			s.logger.Info("proxy_protocol_listening", "addr", proxyAddr)
+			if err := srv.Serve(newProxyProtocolListener(ln)); err != nil && err != http.ErrServerClosed {
+				s.logger.Error("proxy protocol listener error", "err", err)
+			}
+		}()
+	}
+
 	s.logger.Info("http_server_listening", "addr", addr, "backend", s.proxy.Target())
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return err
@@ -203,7 +302,7 @@ func (s *Server) me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	_, _ = fmt.Fprintf(w, `{"email":%q,"name":%q,"picture":%q}`, user.Email, user.Name, user.Picture)
+	_, _ = fmt.Fprintf(w, `{"email":%q,"name":%q,"picture":%q,"csrf_token":%q}`, user.Email, user.Name, user.Picture, CSRFTokenFromContext(r.Context()))
 }
 
 // staticHandler serves the embedded React frontend with smart routing for Next.js static export.
@@ -271,17 +370,26 @@ func (s *Server) securityHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// rateLimitMiddleware throttles repeated auth hits to protect OAuth endpoints.
-func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+// sseConcurrencyMiddleware caps /v1/events to one open stream per user (or per IP for
+// anonymous/dev-bypass connections), via s.sseLimiter.
+func (s *Server) sseConcurrencyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !shouldRateLimit(r.URL.Path) {
-			next.ServeHTTP(w, r)
+		key := sseConcurrencyKey(r, s.trustedProxies)
+		if !s.sseLimiter.acquire(key) {
+			http.Error(w, `{"error":"too many concurrent event streams"}`, http.StatusTooManyRequests)
 			return
 		}
-		ip := clientIP(r)
-		limiter := s.limiters.get(ip)
-		if !limiter.Allow() {
-			s.logger.Warn("rate_limit_exceeded", "ip", ip, "path", r.URL.Path)
+		defer s.sseLimiter.release(key)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitMiddleware enforces defaultRoutePolicies (see ratelimit.go) per IP and,
+// where configured, per authenticated user.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiters.Allow(r) {
+			s.logger.Warn("rate_limit_exceeded", "ip", clientIP(r, s.trustedProxies), "path", r.URL.Path)
 			http.Error(w, "too many requests", http.StatusTooManyRequests)
 			return
 		}
@@ -294,7 +402,7 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip wrapping for SSE endpoint - the wrapper can interfere with HTTP/2 streaming
 		if r.URL.Path == "/v1/events" {
-			s.logger.Info("http_sse_start", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r))
+			s.logger.Info("http_sse_start", "method", r.Method, "path", r.URL.Path, "ip", clientIP(r, s.trustedProxies))
 			next.ServeHTTP(w, r)
 			s.logger.Info("http_sse_end", "method", r.Method, "path", r.URL.Path)
 			return
@@ -315,7 +423,7 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 			"status", sw.status,
 			"duration_ms", time.Since(start).Milliseconds(),
 			"size_bytes", sw.bytes,
-			"ip", clientIP(r),
+			"ip", clientIP(r, s.trustedProxies),
 			"user", user,
 		)
 	})
@@ -345,49 +453,4 @@ func (w *statusWriter) Flush() {
 	}
 }
 
-func clientIP(r *http.Request) string {
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
-type ipRateLimiter struct {
-	mu       sync.Mutex
-	visitors map[string]*rate.Limiter
-	r        rate.Limit
-	burst    int
-}
-
-func newIPRateLimiter(r rate.Limit, burst int) *ipRateLimiter {
-	return &ipRateLimiter{
-		visitors: make(map[string]*rate.Limiter),
-		r:        r,
-		burst:    burst,
-	}
-}
 
-func (l *ipRateLimiter) get(ip string) *rate.Limiter {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	limiter, ok := l.visitors[ip]
-	if !ok {
-		limiter = rate.NewLimiter(l.r, l.burst)
-		l.visitors[ip] = limiter
-	}
-	return limiter
-}
-
-func shouldRateLimit(path string) bool {
-	path = strings.ToLower(path)
-	if path == "/login" || strings.HasPrefix(path, "/auth/") {
-		return true
-	}
-	return false
-}
@@ -7,148 +7,117 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// SSEBroker manages SSE connections and broadcasts events to all connected clients.
-type SSEBroker struct {
-	mu      sync.RWMutex
-	clients map[chan []byte]struct{}
-	logger  *slog.Logger
-
-	// Polling state
-	backend       string
-	pollInterval  time.Duration
-	lastContexts  map[string]contextState // context_id -> state
-	lastPollError error
+// defaultRingBufferSize bounds how many past events SSEBroker retains for
+// Last-Event-ID replay. 1000 events is generous for the ALB idle-timeout/pod-restart
+// reconnect window this exists to cover, without letting the buffer grow unbounded.
+const defaultRingBufferSize = 1000
+
+// bufferedEvent is a broadcast event retained for replay, paired with the wire-format
+// frame it was sent as so reconnecting clients get byte-identical retransmission.
+type bufferedEvent struct {
+	id    uint64
+	msg   []byte
+	event Event
 }
 
-type contextState struct {
-	HeadTurnID string `json:"head_turn_id"`
-	HeadDepth  int    `json:"head_depth"`
+// Event represents an SSE event to broadcast.
+type Event struct {
+	Type string `json:"type"`
+	// ContextID is extracted from Data so SubscribeFilter can match on it without
+	// every ChangeSource implementation having to know about filtering.
+	ContextID string                 `json:"-"`
+	Data      map[string]interface{} `json:"data"`
 }
 
-type contextsResponse struct {
-	Contexts []struct {
-		ContextID       string `json:"context_id"`
-		HeadTurnID      string `json:"head_turn_id"`
-		HeadDepth       int    `json:"head_depth"`
-		CreatedAtUnixMs int64  `json:"created_at_unix_ms"`
-	} `json:"contexts"`
+// SubscribeFilter restricts which events a subscriber receives. A zero-value filter
+// (both fields empty) receives every event, matching the old unfiltered behavior.
+type SubscribeFilter struct {
+	// ContextIDs, when non-empty, only delivers events for these context_ids.
+	ContextIDs []string
+	// EventTypes, when non-empty, only delivers events of these types.
+	EventTypes []string
 }
 
-// NewSSEBroker creates a new SSE broker that polls the backend for changes.
-func NewSSEBroker(backendURL string, logger *slog.Logger) *SSEBroker {
-	return &SSEBroker{
-		clients:      make(map[chan []byte]struct{}),
-		logger:       logger,
-		backend:      backendURL,
-		pollInterval: 2 * time.Second,
-		lastContexts: make(map[string]contextState),
+func (f SubscribeFilter) matches(e Event) bool {
+	if len(f.EventTypes) > 0 && !containsString(f.EventTypes, e.Type) {
+		return false
 	}
+	if len(f.ContextIDs) > 0 && !containsString(f.ContextIDs, e.ContextID) {
+		return false
+	}
+	return true
 }
 
-// Start begins polling the backend for changes.
-func (b *SSEBroker) Start(ctx context.Context) {
-	go b.pollLoop(ctx)
-}
-
-func (b *SSEBroker) pollLoop(ctx context.Context) {
-	ticker := time.NewTicker(b.pollInterval)
-	defer ticker.Stop()
-
-	// Initial poll
-	b.poll()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			b.poll()
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
 		}
 	}
+	return false
 }
 
-func (b *SSEBroker) poll() {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", b.backend+"/v1/contexts?limit=50", nil)
-	if err != nil {
-		b.lastPollError = err
-		return
-	}
+type subscriber struct {
+	ch     chan []byte
+	filter SubscribeFilter
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		b.lastPollError = err
-		return
-	}
-	defer func() { _ = resp.Body.Close() }()
+// SSEBroker manages SSE connections and fans out events from a ChangeSource to every
+// client whose SubscribeFilter matches, so a UI viewing one conversation doesn't get
+// flooded with unrelated events.
+type SSEBroker struct {
+	mu          sync.RWMutex
+	subscribers map[chan []byte]*subscriber
+	logger      *slog.Logger
+	source      ChangeSource
+
+	nextID   uint64
+	ring     []bufferedEvent
+	ringSize int
+}
 
-	if resp.StatusCode != http.StatusOK {
-		b.lastPollError = fmt.Errorf("backend returned %d", resp.StatusCode)
-		return
+// NewSSEBroker creates a broker that fans out events produced by source. Pass a
+// *PollingChangeSource for the original polling behavior, or a push-based source
+// (*PostgresChangeSource, *PubSubChangeSource) for real-time delivery.
+func NewSSEBroker(source ChangeSource, logger *slog.Logger) *SSEBroker {
+	return &SSEBroker{
+		subscribers: make(map[chan []byte]*subscriber),
+		logger:      logger,
+		source:      source,
+		ringSize:    defaultRingBufferSize,
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// Start begins consuming events from the broker's ChangeSource.
+func (b *SSEBroker) Start(ctx context.Context) {
+	events, err := b.source.Start(ctx)
 	if err != nil {
-		b.lastPollError = err
-		return
-	}
-
-	var data contextsResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		b.lastPollError = err
+		b.logger.Error("change source start failed", "err", err)
 		return
 	}
+	go b.fanOut(ctx, events)
+}
 
-	b.lastPollError = nil
-
-	// Check for new/updated contexts
-	newContexts := make(map[string]contextState)
-	for _, ctx := range data.Contexts {
-		newContexts[ctx.ContextID] = contextState{
-			HeadTurnID: ctx.HeadTurnID,
-			HeadDepth:  ctx.HeadDepth,
-		}
-
-		oldState, exists := b.lastContexts[ctx.ContextID]
-		if !exists {
-			// New context
-			b.broadcast(Event{
-				Type: "context_created",
-				Data: map[string]interface{}{
-					"context_id": ctx.ContextID,
-					"created_at": ctx.CreatedAtUnixMs,
-				},
-			})
-		} else if oldState.HeadTurnID != ctx.HeadTurnID {
-			// Turn appended
-			b.broadcast(Event{
-				Type: "turn_appended",
-				Data: map[string]interface{}{
-					"context_id":     ctx.ContextID,
-					"turn_id":        ctx.HeadTurnID,
-					"parent_turn_id": oldState.HeadTurnID,
-					"depth":          ctx.HeadDepth,
-				},
-			})
+func (b *SSEBroker) fanOut(ctx context.Context, events <-chan Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			b.broadcast(event)
 		}
 	}
-
-	b.lastContexts = newContexts
-}
-
-// Event represents an SSE event to broadcast.
-type Event struct {
-	Type string                 `json:"type"`
-	Data map[string]interface{} `json:"data"`
 }
 
 func (b *SSEBroker) broadcast(event Event) {
@@ -158,43 +127,109 @@ func (b *SSEBroker) broadcast(event Event) {
 		return
 	}
 
-	// Format as SSE: event: <type>\ndata: <json>\n\n
-	msg := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data))
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	msg := []byte(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", id, event.Type, data))
 
-	b.mu.RLock()
-	defer b.mu.RUnlock()
+	b.ring = append(b.ring, bufferedEvent{id: id, msg: msg, event: event})
+	if len(b.ring) > b.ringSize {
+		b.ring = b.ring[len(b.ring)-b.ringSize:]
+	}
 
-	for ch := range b.clients {
+	subs := make([]*subscriber, 0, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		subs = append(subs, sub)
+	}
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		if !sub.filter.matches(event) {
+			continue
+		}
 		select {
-		case ch <- msg:
+		case sub.ch <- msg:
 		default:
-			// Client buffer full, skip
+			// Client buffer full, skip.
 		}
 	}
 }
 
-// Subscribe adds a client to receive events.
-func (b *SSEBroker) Subscribe() chan []byte {
+// Subscribe adds a client to receive events matching filter.
+func (b *SSEBroker) Subscribe(filter SubscribeFilter) chan []byte {
+	ch, _ := b.subscribeFrom(filter, 0)
+	return ch
+}
+
+// subscribeFrom registers ch under the subscriber map and collects any buffered
+// events with id > lastEventID matching filter, atomically with subscribing so no
+// event broadcast between "client disconnected" and "client resubscribed" is missed
+// or double-delivered.
+func (b *SSEBroker) subscribeFrom(filter SubscribeFilter, lastEventID uint64) (chan []byte, []bufferedEvent) {
 	ch := make(chan []byte, 10)
 	b.mu.Lock()
-	b.clients[ch] = struct{}{}
+	b.subscribers[ch] = &subscriber{ch: ch, filter: filter}
+	var replay []bufferedEvent
+	if lastEventID > 0 {
+		for _, be := range b.ring {
+			if be.id > lastEventID && filter.matches(be.event) {
+				replay = append(replay, be)
+			}
+		}
+	}
 	b.mu.Unlock()
-	b.logger.Info("sse_client_connected", "total_clients", len(b.clients))
-	return ch
+	b.logger.Info("sse_client_connected", "total_clients", b.ClientCount(), "replayed_events", len(replay))
+	return ch, replay
 }
 
 // Unsubscribe removes a client.
 func (b *SSEBroker) Unsubscribe(ch chan []byte) {
 	b.mu.Lock()
-	delete(b.clients, ch)
+	delete(b.subscribers, ch)
 	close(ch)
 	b.mu.Unlock()
-	b.logger.Info("sse_client_disconnected", "total_clients", len(b.clients))
+	b.logger.Info("sse_client_disconnected", "total_clients", b.ClientCount())
+}
+
+// lastEventID resolves the standard Last-Event-ID header, falling back to
+// ?lastEventId= for EventSource polyfills that can't set custom headers on the
+// initial GET (e.g. IE's XDomainRequest-based shims).
+func lastEventID(r *http.Request) uint64 {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
 }
 
-// ServeHTTP handles SSE connections at /v1/events.
+func filterFromRequest(r *http.Request) SubscribeFilter {
+	q := r.URL.Query()
+	return SubscribeFilter{
+		ContextIDs: splitNonEmpty(q.Get("context_id")),
+		EventTypes: splitNonEmpty(q.Get("event")),
+	}
+}
+
+func splitNonEmpty(csv string) []string {
+	if csv == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(csv, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ServeHTTP handles SSE connections at /v1/events. Clients may scope their
+// subscription with ?context_id=a,b and/or ?event=turn_appended,context_created.
 func (b *SSEBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Check if client supports SSE
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "SSE not supported", http.StatusInternalServerError)
@@ -210,41 +245,38 @@ func (b *SSEBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 
-	// Subscribe to events
-	ch := b.Subscribe()
+	ch, replay := b.subscribeFrom(filterFromRequest(r), lastEventID(r))
 	defer b.Unsubscribe(ch)
 
-	// Send minimal initial message - just retry and comment
-	b.logger.Info("sse_sending_connected")
 	_, _ = fmt.Fprintf(w, "retry: 10000\n\n")
 	flusher.Flush()
 
-	// Send connected event
 	_, _ = fmt.Fprintf(w, "event: connected\ndata: {\"status\":\"connected\"}\n\n")
 	flusher.Flush()
-	b.logger.Info("sse_flushed_connected")
 
-	// Keep-alive ticker to prevent ALB/proxy timeouts
+	for _, be := range replay {
+		if _, err := w.Write(be.msg); err != nil {
+			return
+		}
+	}
+	if len(replay) > 0 {
+		flusher.Flush()
+	}
+
 	keepAlive := time.NewTicker(5 * time.Second)
 	defer keepAlive.Stop()
 
-	// Stream events until client disconnects
-	b.logger.Info("sse_entering_loop")
 	for {
 		select {
 		case <-r.Context().Done():
-			b.logger.Info("sse_context_done", "err", r.Context().Err())
 			return
 		case <-keepAlive.C:
-			// Send SSE comment as keep-alive (: comment\n\n)
-			_, err := fmt.Fprintf(w, ": keepalive %d\n\n", time.Now().Unix())
-			if err != nil {
+			if _, err := fmt.Fprintf(w, ": keepalive %d\n\n", time.Now().Unix()); err != nil {
 				return
 			}
 			flusher.Flush()
 		case msg := <-ch:
-			_, err := w.Write(msg)
-			if err != nil {
+			if _, err := w.Write(msg); err != nil {
 				return
 			}
 			flusher.Flush()
@@ -256,5 +288,5 @@ func (b *SSEBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (b *SSEBroker) ClientCount() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return len(b.clients)
+	return len(b.subscribers)
 }